@@ -2,11 +2,17 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"time"
 
 	"github.com/cncd/bitbucket-frontend"
+	"github.com/cncd/bitbucket-frontend/metadata"
 	"github.com/cncd/pipeline/pipeline/frontend"
 
 	"github.com/urfave/cli"
@@ -16,7 +22,7 @@ var compileCommand = cli.Command{
 	Name:   "compile",
 	Usage:  "compile the yaml file",
 	Action: compileAction,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.StringFlag{
 			Name:  "in",
 			Value: "bitbucket-pipelines.yml",
@@ -25,230 +31,278 @@ var compileCommand = cli.Command{
 			Name:  "out",
 			Value: "pipeline.json",
 		},
-		cli.StringSliceFlag{
-			Name: "volumes",
-		},
-		cli.StringSliceFlag{
-			Name: "privileged",
-			Value: &cli.StringSlice{
-				"plugins/docker",
-				"plugins/gcr",
-				"plugins/ecr",
-			},
-		},
 		cli.StringFlag{
-			Name:  "prefix",
-			Value: "pipeline",
+			Name:  "format",
+			Value: "json",
+			Usage: "output format (json, yaml, json-compact)",
 		},
 		cli.BoolFlag{
-			Name: "local",
-		},
-		//
-		// workspace default
-		//
-		cli.StringFlag{
-			Name:  "workspace-base",
-			Value: "/workspace",
-		},
-		cli.StringFlag{
-			Name:  "workspace-path",
-			Value: "src",
-		},
-		//
-		// netrc parameters
-		//
-		cli.StringFlag{
-			Name:   "netrc-username",
-			EnvVar: "CI_NETRC_USERNAME",
-		},
-		cli.StringFlag{
-			Name:   "netrc-password",
-			EnvVar: "CI_NETRC_PASSWORD",
-		},
-		cli.StringFlag{
-			Name:   "netrc-machine",
-			EnvVar: "CI_NETRC_MACHINE",
-		},
-		//
-		// metadata parameters
-		//
-		cli.StringFlag{
-			Name:   "system-arch",
-			Value:  "linux/amd64",
-			EnvVar: "CI_SYSTEM_ARCH",
-		},
-		cli.StringFlag{
-			Name:   "system-name",
-			Value:  "pipec",
-			EnvVar: "CI_SYSTEM_NAME",
-		},
-		cli.StringFlag{
-			Name:   "system-link",
-			Value:  "https://github.com/cncd/pipec",
-			EnvVar: "CI_SYSTEM_LINK",
-		},
-		cli.StringFlag{
-			Name:   "repo-name",
-			EnvVar: "CI_REPO_NAME",
-		},
-		cli.StringFlag{
-			Name:   "repo-link",
-			EnvVar: "CI_REPO_LINK",
-		},
-		cli.StringFlag{
-			Name:   "repo-remote-url",
-			EnvVar: "CI_REPO_REMOTE",
-		},
-		cli.StringFlag{
-			Name:   "repo-private",
-			EnvVar: "CI_REPO_PRIVATE",
-		},
-		cli.IntFlag{
-			Name:   "build-number",
-			EnvVar: "CI_BUILD_NUMBER",
-		},
-		cli.Int64Flag{
-			Name:   "build-created",
-			EnvVar: "CI_BUILD_CREATED",
-		},
-		cli.Int64Flag{
-			Name:   "build-started",
-			EnvVar: "CI_BUILD_STARTED",
-		},
-		cli.Int64Flag{
-			Name:   "build-finished",
-			EnvVar: "CI_BUILD_FINISHED",
-		},
-		cli.StringFlag{
-			Name:   "build-status",
-			EnvVar: "CI_BUILD_STATUS",
-		},
-		cli.StringFlag{
-			Name:   "build-event",
-			EnvVar: "CI_BUILD_EVENT",
-		},
-		cli.StringFlag{
-			Name:   "build-link",
-			EnvVar: "CI_BUILD_LINK",
-		},
-		cli.StringFlag{
-			Name:   "build-target",
-			EnvVar: "CI_BUILD_TARGET",
-		},
-		cli.StringFlag{
-			Name:   "commit-sha",
-			EnvVar: "CI_COMMIT_SHA",
-		},
-		cli.StringFlag{
-			Name:   "commit-ref",
-			EnvVar: "CI_COMMIT_REF",
-		},
-		cli.StringFlag{
-			Name:   "commit-refspec",
-			EnvVar: "CI_COMMIT_REFSPEC",
-		},
-		cli.StringFlag{
-			Name:   "commit-branch",
-			EnvVar: "CI_COMMIT_BRANCH",
-		},
-		cli.StringFlag{
-			Name:   "commit-message",
-			EnvVar: "CI_COMMIT_MESSAGE",
-		},
-		cli.StringFlag{
-			Name:   "commit-author-name",
-			EnvVar: "CI_COMMIT_AUTHOR_NAME",
-		},
-		cli.StringFlag{
-			Name:   "commit-author-avatar",
-			EnvVar: "CI_COMMIT_AUTHOR_AVATAR",
-		},
-		cli.StringFlag{
-			Name:   "commit-author-email",
-			EnvVar: "CI_COMMIT_AUTHOR_EMAIL",
-		},
-		cli.IntFlag{
-			Name:   "prev-build-number",
-			EnvVar: "CI_PREV_BUILD_NUMBER",
-		},
-		cli.Int64Flag{
-			Name:   "prev-build-created",
-			EnvVar: "CI_PREV_BUILD_CREATED",
+			Name:  "decode-script",
+			Usage: "decode the script of each step in the yaml output",
 		},
-		cli.Int64Flag{
-			Name:   "prev-build-started",
-			EnvVar: "CI_PREV_BUILD_STARTED",
-		},
-		cli.Int64Flag{
-			Name:   "prev-build-finished",
-			EnvVar: "CI_PREV_BUILD_FINISHED",
-		},
-		cli.StringFlag{
-			Name:   "prev-build-status",
-			EnvVar: "CI_PREV_BUILD_STATUS",
-		},
-		cli.StringFlag{
-			Name:   "prev-build-event",
-			EnvVar: "CI_PREV_BUILD_EVENT",
-		},
-		cli.StringFlag{
-			Name:   "prev-build-link",
-			EnvVar: "CI_PREV_BUILD_LINK",
-		},
-		cli.StringFlag{
-			Name:   "prev-commit-sha",
-			EnvVar: "CI_PREV_COMMIT_SHA",
-		},
-		cli.StringFlag{
-			Name:   "prev-commit-ref",
-			EnvVar: "CI_PREV_COMMIT_REF",
-		},
-		cli.StringFlag{
-			Name:   "prev-commit-refspec",
-			EnvVar: "CI_PREV_COMMIT_REFSPEC",
-		},
-		cli.StringFlag{
-			Name:   "prev-commit-branch",
-			EnvVar: "CI_PREV_COMMIT_BRANCH",
+		cli.BoolFlag{
+			Name:  "all",
+			Usage: "compile every pipeline in the yaml file",
 		},
 		cli.StringFlag{
-			Name:   "prev-commit-message",
-			EnvVar: "CI_PREV_COMMIT_MESSAGE",
+			Name:  "out-dir",
+			Usage: "write each pipeline compiled with --all to a file in the directory",
 		},
-		cli.StringFlag{
-			Name:   "prev-commit-author-name",
-			EnvVar: "CI_PREV_COMMIT_AUTHOR_NAME",
+		cli.BoolFlag{
+			Name:  "watch",
+			Usage: "recompile when the yaml file or an imported file changes",
 		},
-		cli.StringFlag{
-			Name:   "prev-commit-author-avatar",
-			EnvVar: "CI_PREV_COMMIT_AUTHOR_AVATAR",
+		cli.DurationFlag{
+			Name:  "watch-interval",
+			Value: time.Second,
+			Usage: "interval at which watched files are checked for changes",
 		},
 		cli.StringFlag{
-			Name:   "prev-commit-author-email",
-			EnvVar: "CI_PREV_COMMIT_AUTHOR_EMAIL",
+			Name:  "hook",
+			Usage: "command executed after each successful recompile in watch mode, requires --local",
 		},
-		cli.IntFlag{
-			Name:   "job-number",
-			EnvVar: "CI_JOB_NUMBER",
+	}, compilerFlags...),
+}
+
+// compilerFlags are the flags used to parse and compile the yaml file.
+var compilerFlags = append([]cli.Flag{
+	cli.StringFlag{
+		Name:  "imports",
+		Usage: "directory containing repositories with exported pipelines",
+	},
+	cli.StringSliceFlag{
+		Name: "volumes",
+	},
+	cli.StringSliceFlag{
+		Name: "privileged",
+		Value: &cli.StringSlice{
+			"plugins/docker",
+			"plugins/gcr",
+			"plugins/ecr",
 		},
-		// cli.StringFlag{
-		// 	Name:   "job-matrix",
-		// 	EnvVar: "CI_JOB_MATRIX",
-		// },
 	},
+	cli.StringFlag{
+		Name:  "prefix",
+		Value: "pipeline",
+	},
+	cli.BoolFlag{
+		Name: "local",
+	},
+	cli.BoolFlag{
+		Name:  "manual",
+		Usage: "include steps that must be triggered manually",
+	},
+	cli.StringFlag{
+		Name:  "shell",
+		Usage: "shell used to run scripts (default bash, falling back to sh)",
+	},
+	cli.BoolFlag{
+		Name:  "pipefail",
+		Usage: "fail scripts when any command in a pipeline fails",
+	},
+	cli.BoolFlag{
+		Name:  "trace-markers",
+		Usage: "write start and end markers for each command to the logs",
+	},
+	//
+	// workspace default
+	//
+	cli.StringFlag{
+		Name:  "workspace-base",
+		Value: "/workspace",
+	},
+	cli.StringFlag{
+		Name:  "workspace-path",
+		Value: "src",
+	},
+	//
+	// netrc parameters
+	//
+	cli.StringFlag{
+		Name:   "netrc-username",
+		EnvVar: "CI_NETRC_USERNAME",
+	},
+	cli.StringFlag{
+		Name:   "netrc-password",
+		EnvVar: "CI_NETRC_PASSWORD",
+	},
+	cli.StringFlag{
+		Name:   "netrc-machine",
+		EnvVar: "CI_NETRC_MACHINE",
+	},
+	//
+	// secret parameters
+	//
+	cli.StringSliceFlag{
+		Name:  "secret",
+		Usage: "name of an environment variable to pass as a secret",
+	},
+	//
+	// pipeline variables
+	//
+	cli.StringSliceFlag{
+		Name:  "workspace-env-file",
+		Usage: "file with workspace variables",
+	},
+	cli.StringSliceFlag{
+		Name:  "env-file",
+		Usage: "file with repository variables, overriding workspace variables",
+	},
+	cli.StringSliceFlag{
+		Name:  "deployment-env-file",
+		Usage: "file with deployment variables in the format name=file",
+	},
+	cli.StringSliceFlag{
+		Name:  "var",
+		Usage: "variable in the format KEY=VALUE, overriding all other variables",
+	},
+	cli.StringFlag{
+		Name:  "http-proxy",
+		Usage: "http proxy passed to every container",
+	},
+	cli.StringFlag{
+		Name:  "https-proxy",
+		Usage: "https proxy passed to every container",
+	},
+	cli.StringFlag{
+		Name:  "no-proxy",
+		Usage: "hosts excluded from the proxy",
+	},
+	//
+	// metadata parameters
+	//
+	cli.BoolFlag{
+		Name:  "git",
+		Usage: "derive the metadata from the git repository of the yaml file",
+	},
+	cli.StringFlag{
+		Name:  "webhook",
+		Usage: "bitbucket webhook payload to derive the metadata from, or - for stdin",
+	},
+	cli.StringFlag{
+		Name:   "event-key",
+		Usage:  "event key of the webhook payload, such as repo:push or repo:refs_changed",
+		EnvVar: "X_EVENT_KEY",
+	},
+	cli.IntFlag{
+		Name:  "webhook-change",
+		Usage: "index of the build to compile when a push contains multiple changes",
+	},
+	cli.StringFlag{
+		Name:  "metadata",
+		Usage: "json file with the build metadata, or - for stdin, overridden by the metadata flags",
+	},
+}, metadataFlags()...)
+
+// metadataFlags returns the flags setting the metadata fields, which
+// are defined once in the metadata package.
+func metadataFlags() []cli.Flag {
+	var flags []cli.Flag
+	for _, field := range metadata.Fields {
+		switch field.Kind() {
+		case reflect.Bool:
+			flags = append(flags, cli.BoolFlag{Name: field.Name, EnvVar: field.EnvVar})
+		case reflect.Int:
+			flags = append(flags, cli.IntFlag{Name: field.Name, EnvVar: field.EnvVar})
+		case reflect.Int64:
+			flags = append(flags, cli.Int64Flag{Name: field.Name, EnvVar: field.EnvVar})
+		default:
+			flags = append(flags, cli.StringFlag{Name: field.Name, Value: field.Default, EnvVar: field.EnvVar})
+		}
+	}
+	return flags
+}
+
+func compileAction(c *cli.Context) error {
+	if c.Bool("watch") {
+		return watchAction(c)
+	}
+
+	file, conf, err := parseFromContext(c)
+	if err != nil {
+		return err
+	}
+	return writeCompiled(c, file, conf)
+}
+
+// writeCompiled compiles the configuration and writes the compiled
+// pipeline to the output file.
+func writeCompiled(c *cli.Context, file string, conf *bitbucket.Config) (err error) {
+	compiler, err := compilerFromContext(c, file)
+	if err != nil {
+		return err
+	}
+	if c.Bool("all") {
+		return compileAll(c, file, compiler, conf)
+	}
+	compiled, err := compiler.Compile(conf)
+	if err != nil {
+		return err
+	}
+
+	// marshal the compiled spec to the output format
+	out, err := bitbucket.Marshal(compiled, c.String("format"),
+		bitbucket.WithDecodedScript(c.Bool("decode-script")),
+	)
+	if err != nil {
+		return err
+	}
+
+	// create output file with option to dump to stdout
+	var writer = os.Stdout
+	output := c.String("out")
+	if output != "-" {
+		writer, err = os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer writer.Close()
+	}
+
+	_, err = writer.Write(out)
+	if err != nil {
+		return err
+	}
+
+	if writer != os.Stdout {
+		fmt.Fprintf(os.Stdout, "Successfully compiled %s to %s\n", file, output)
+	}
+	return nil
 }
 
-func compileAction(c *cli.Context) (err error) {
+// parseFromContext parses the yaml file named by the command line
+// arguments or the in flag.
+func parseFromContext(c *cli.Context) (string, *bitbucket.Config, error) {
 	file := c.Args().First()
 	if file == "" {
 		file = c.String("in")
 	}
+	conf, err := parseFile(c, file)
+	return file, conf, err
+}
+
+// parseFile parses the yaml file, resolving imports from the directory
+// given by the imports flag.
+func parseFile(c *cli.Context, file string) (*bitbucket.Config, error) {
+	var opts []bitbucket.ParseOption
+	if resolver := resolverFromContext(c); resolver != nil {
+		opts = append(opts, bitbucket.WithResolver(resolver))
+	}
+	return bitbucket.ParseFile(file, opts...)
+}
 
-	conf, err := bitbucket.ParseFile(file)
-	if err != nil {
-		return err
+// resolverFromContext returns the resolver reading imported pipelines
+// from the directory given by the imports flag, or nil.
+func resolverFromContext(c *cli.Context) *bitbucket.FileResolver {
+	if dir := c.String("imports"); dir != "" {
+		return bitbucket.NewFileResolver(dir)
 	}
+	return nil
+}
 
+// compilerFromContext returns a compiler configured from the command
+// line flags.
+func compilerFromContext(c *cli.Context, file string) (*bitbucket.Compiler, error) {
 	// configure volumes for local execution
 	volumes := c.StringSlice("volumes")
 	if c.Bool("local") {
@@ -261,8 +315,17 @@ func compileAction(c *cli.Context) (err error) {
 		volumes = append(volumes, dir+":"+workspace)
 	}
 
+	meta, err := metadataFromContext(c, file)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := variablesFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+
 	// compiles the yaml file
-	compiled := bitbucket.NewCompiler(
+	opts := []bitbucket.Option{
 		bitbucket.WithVolumes(volumes...),
 		bitbucket.WithWorkspace(
 			c.String("workspace-base"),
@@ -274,104 +337,191 @@ func compileAction(c *cli.Context) (err error) {
 		bitbucket.WithLocal(
 			c.Bool("local"),
 		),
+		bitbucket.WithManual(
+			c.Bool("manual"),
+		),
+		bitbucket.WithShell(
+			c.String("shell"),
+		),
+		bitbucket.WithPipefail(
+			c.Bool("pipefail"),
+		),
+		bitbucket.WithTraceMarkers(
+			c.Bool("trace-markers"),
+		),
 		bitbucket.WithNetrc(
 			c.String("netrc-username"),
 			c.String("netrc-password"),
 			c.String("netrc-machine"),
 		),
-		bitbucket.WithMetadata(
-			metadataFromContext(c),
+		bitbucket.WithMetadata(meta),
+		bitbucket.WithSecrets(
+			secretsFromContext(c),
 		),
-	).Compile(conf)
+		bitbucket.WithRegisteredTransforms(),
+	}
+	opts = append(opts, variables...)
+	return bitbucket.NewCompiler(opts...), nil
+}
+
+// variablesFromContext returns the options configuring the pipeline
+// variables and proxies from the cli context.
+func variablesFromContext(c *cli.Context) ([]bitbucket.Option, error) {
+	variables := &bitbucket.Variables{
+		HTTPProxy:       c.String("http-proxy"),
+		HTTPSProxy:      c.String("https-proxy"),
+		NoProxy:         c.String("no-proxy"),
+		WorkspaceFiles:  c.StringSlice("workspace-env-file"),
+		RepositoryFiles: c.StringSlice("env-file"),
+		DeploymentFiles: c.StringSlice("deployment-env-file"),
+		Overrides:       c.StringSlice("var"),
+	}
+	return variables.Options()
+}
 
-	// marshal the compiled spec to formatted yaml
-	out, err := json.MarshalIndent(compiled, "", "  ")
+// compileAll compiles every pipeline in the yaml file and writes the
+// result to a single json document, or to one file per pipeline in
+// the output directory.
+func compileAll(c *cli.Context, file string, compiler *bitbucket.Compiler, conf *bitbucket.Config) error {
+	compiled, err := compiler.CompileAll(conf)
 	if err != nil {
 		return err
 	}
 
-	// create output file with option to dump to stdout
-	var writer = os.Stdout
-	output := c.String("out")
-	if output != "-" {
-		writer, err = os.Create(output)
+	format := c.String("format")
+	decode := bitbucket.WithDecodedScript(c.Bool("decode-script"))
+
+	dir := c.String("out-dir")
+	if dir == "" {
+		out, err := bitbucket.Marshal(compiled, format, decode)
+		if err != nil {
+			return err
+		}
+		output := c.String("out")
+		if output == "-" {
+			_, err = os.Stdout.Write(out)
+			return err
+		}
+		err = ioutil.WriteFile(output, out, 0644)
 		if err != nil {
 			return err
 		}
+		fmt.Fprintf(os.Stdout, "Successfully compiled %s to %s\n", file, output)
+		return nil
 	}
-	defer writer.Close()
 
-	_, err = writer.Write(out)
+	ext := ".json"
+	if format == bitbucket.FormatYAML {
+		ext = ".yaml"
+	}
+	for selector, spec := range compiled {
+		out, err := bitbucket.Marshal(spec, format, decode)
+		if err != nil {
+			return err
+		}
+		// the pattern is escaped so that wildcards and path
+		// separators produce a single valid file name.
+		name := selector.Section
+		if selector.Pattern != "" {
+			name = filepath.Join(name, url.QueryEscape(selector.Pattern))
+		}
+		output := filepath.Join(dir, name+ext)
+		err = os.MkdirAll(filepath.Dir(output), 0755)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(output, out, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stdout, "Successfully compiled %d pipelines in %s to %s\n", len(compiled), file, dir)
+	return nil
+}
+
+// return the secrets from the cli context. The secret values are read
+// from the environment.
+func secretsFromContext(c *cli.Context) map[string]string {
+	secrets := map[string]string{}
+	for _, name := range c.StringSlice("secret") {
+		secrets[name] = os.Getenv(name)
+	}
+	return secrets
+}
+
+// metadataFromContext returns the metadata from the cli context. The
+// metadata derived from the git repository of the yaml file or from a
+// webhook payload is layered under the metadata file, which is layered
+// under the flags. If any of these sources is used, only the flags that
+// are set explicitly or through their environment variable override
+// the metadata.
+func metadataFromContext(c *cli.Context, file string) (frontend.Metadata, error) {
+	var m frontend.Metadata
+	var err error
+	switch {
+	case c.Bool("git") && c.String("webhook") != "":
+		return m, errors.New("the git and webhook flags cannot be combined")
+	case c.Bool("git"):
+		if m, err = metadata.FromGit(filepath.Dir(file)); err != nil {
+			return m, err
+		}
+	case c.String("webhook") != "":
+		if m, err = webhookMetadata(c); err != nil {
+			return m, err
+		}
+	}
+	if path := c.String("metadata"); path != "" {
+		if err := readMetadata(path, &m); err != nil {
+			return m, err
+		}
+	}
+	based := c.Bool("git") || c.String("webhook") != "" || c.String("metadata") != ""
+
+	values := map[string]string{}
+	for _, field := range metadata.Fields {
+		if !based || c.IsSet(field.Name) {
+			values[field.Name] = c.String(field.Name)
+		}
+	}
+	return metadata.Layer(m, values)
+}
+
+// readMetadata reads the json metadata from the file, or from stdin if
+// the file is -, into the metadata.
+func readMetadata(file string, m *frontend.Metadata) error {
+	data, err := readInput(file)
 	if err != nil {
 		return err
 	}
-
-	if writer != os.Stdout {
-		fmt.Fprintf(os.Stdout, "Successfully compiled %s to %s\n", file, output)
+	if err := json.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("invalid metadata %s: %s", file, err)
 	}
 	return nil
 }
 
-// return the metadata from the cli context.
-func metadataFromContext(c *cli.Context) frontend.Metadata {
-	return frontend.Metadata{
-		Repo: frontend.Repo{
-			Name:    c.String("repo-name"),
-			Link:    c.String("repo-link"),
-			Remote:  c.String("repo-remote-url"),
-			Private: c.Bool("repo-private"),
-		},
-		Curr: frontend.Build{
-			Number:   c.Int("build-number"),
-			Created:  c.Int64("build-created"),
-			Started:  c.Int64("build-started"),
-			Finished: c.Int64("build-finished"),
-			Status:   c.String("build-status"),
-			Event:    c.String("build-event"),
-			Link:     c.String("build-link"),
-			Target:   c.String("build-target"),
-			Commit: frontend.Commit{
-				Sha:     c.String("commit-sha"),
-				Ref:     c.String("commit-ref"),
-				Refspec: c.String("commit-refspec"),
-				Branch:  c.String("commit-branch"),
-				Message: c.String("commit-message"),
-				Author: frontend.Author{
-					Name:   c.String("commit-author-name"),
-					Email:  c.String("commit-author-email"),
-					Avatar: c.String("commit-author-avatar"),
-				},
-			},
-		},
-		Prev: frontend.Build{
-			Number:   c.Int("prev-build-number"),
-			Created:  c.Int64("prev-build-created"),
-			Started:  c.Int64("prev-build-started"),
-			Finished: c.Int64("prev-build-finished"),
-			Status:   c.String("prev-build-status"),
-			Event:    c.String("prev-build-event"),
-			Link:     c.String("prev-build-link"),
-			Commit: frontend.Commit{
-				Sha:     c.String("prev-commit-sha"),
-				Ref:     c.String("prev-commit-ref"),
-				Refspec: c.String("prev-commit-refspec"),
-				Branch:  c.String("prev-commit-branch"),
-				Message: c.String("prev-commit-message"),
-				Author: frontend.Author{
-					Name:   c.String("prev-commit-author-name"),
-					Email:  c.String("prev-commit-author-email"),
-					Avatar: c.String("prev-commit-author-avatar"),
-				},
-			},
-		},
-		Job: frontend.Job{
-			Number: c.Int("job-number"),
-			// Matrix: ,
-		},
-		Sys: frontend.System{
-			Name: c.String("system-name"),
-			Link: c.String("system-link"),
-			Arch: c.String("system-arch"),
-		},
+// webhookMetadata returns the metadata of the build selected by the
+// webhook-change flag among the builds triggered by the webhook.
+func webhookMetadata(c *cli.Context) (frontend.Metadata, error) {
+	var m frontend.Metadata
+	payload, err := readInput(c.String("webhook"))
+	if err != nil {
+		return m, err
+	}
+	builds, err := metadata.FromWebhook(c.String("event-key"), payload)
+	if err != nil {
+		return m, err
+	}
+	index := c.Int("webhook-change")
+	if index < 0 || index >= len(builds) {
+		return m, fmt.Errorf("webhook change %d out of range, the webhook triggers %d builds", index, len(builds))
+	}
+	return builds[index], nil
+}
+
+// readInput reads the file, or stdin if the file is -.
+func readInput(file string) ([]byte, error) {
+	if file == "-" {
+		return ioutil.ReadAll(os.Stdin)
 	}
+	return ioutil.ReadFile(file)
 }
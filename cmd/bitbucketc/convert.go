@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cncd/bitbucket-frontend"
+	"github.com/cncd/bitbucket-frontend/convert"
+
+	"github.com/urfave/cli"
+)
+
+var convertCommand = cli.Command{
+	Name:   "convert",
+	Usage:  "convert the yaml file to another ci configuration format",
+	Action: convertAction,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "in",
+			Value: "bitbucket-pipelines.yml",
+		},
+		cli.StringFlag{
+			Name:  "to",
+			Usage: "target format (" + strings.Join(convert.Targets(), ", ") + ")",
+		},
+		cli.StringFlag{
+			Name:  "out-dir",
+			Value: ".",
+			Usage: "directory to write the converted files, or - for stdout",
+		},
+		cli.StringFlag{
+			Name:  "imports",
+			Usage: "directory containing repositories with exported pipelines",
+		},
+	},
+}
+
+func convertAction(c *cli.Context) error {
+	file := c.Args().First()
+	if file == "" {
+		file = c.String("in")
+	}
+
+	var opts []bitbucket.ParseOption
+	if dir := c.String("imports"); dir != "" {
+		opts = append(opts, bitbucket.WithResolver(
+			bitbucket.NewFileResolver(dir),
+		))
+	}
+
+	conf, err := bitbucket.ParseFile(file, opts...)
+	if err != nil {
+		return err
+	}
+
+	result, err := convert.Convert(conf, c.String("to"))
+	if err != nil {
+		return err
+	}
+
+	dir := c.String("out-dir")
+	for _, converted := range result.Files {
+		if dir == "-" {
+			fmt.Fprintf(os.Stdout, "# %s\n%s", converted.Name, converted.Data)
+			continue
+		}
+		output := filepath.Join(dir, converted.Name)
+		err = os.MkdirAll(filepath.Dir(output), 0755)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(output, converted.Data, 0644)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "Successfully converted %s to %s\n", file, output)
+	}
+
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cncd/bitbucket-frontend"
+	"github.com/cncd/pipeline/pipeline/backend"
+
+	"github.com/urfave/cli"
+)
+
+var diffCommand = cli.Command{
+	Name:      "diff",
+	Usage:     "compare the pipelines of two yaml files or compiled outputs",
+	ArgsUsage: "<old> <new>",
+	Action:    diffAction,
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format (text, json)",
+		},
+		cli.BoolFlag{
+			Name:  "exit-code",
+			Usage: "exit with status 1 if the pipelines differ",
+		},
+	}, compilerFlags...),
+}
+
+func diffAction(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return errors.New("diff requires the old and new files")
+	}
+	from, to := c.Args().Get(0), c.Args().Get(1)
+
+	// yaml files are compared pipeline by pipeline. If either
+	// file is a compiled pipeline, the yaml file is compiled for
+	// the pipeline selected by the metadata.
+	var changes []*bitbucket.Change
+	if isCompiled(from) || isCompiled(to) {
+		a, err := loadPipeline(c, from)
+		if err != nil {
+			return err
+		}
+		b, err := loadPipeline(c, to)
+		if err != nil {
+			return err
+		}
+		changes = bitbucket.Diff(a, b)
+	} else {
+		a, err := loadPipelines(c, from)
+		if err != nil {
+			return err
+		}
+		b, err := loadPipelines(c, to)
+		if err != nil {
+			return err
+		}
+		changes = bitbucket.DiffAll(a, b)
+	}
+
+	switch format := c.String("format"); format {
+	case "json":
+		if changes == nil {
+			changes = []*bitbucket.Change{}
+		}
+		out, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", out)
+	case "text":
+		for _, change := range changes {
+			fmt.Fprintln(os.Stdout, change)
+		}
+	default:
+		return fmt.Errorf("unsupported diff format %q", format)
+	}
+
+	if len(changes) != 0 && c.Bool("exit-code") {
+		return cli.NewExitError("", 1)
+	}
+	return nil
+}
+
+// isCompiled returns true if the file is a compiled pipeline.
+func isCompiled(file string) bool {
+	return filepath.Ext(file) == ".json"
+}
+
+// loadPipeline returns the compiled pipeline from the json file, or
+// compiles the pipeline of the yaml file selected by the metadata.
+func loadPipeline(c *cli.Context, file string) (*backend.Config, error) {
+	if isCompiled(file) {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		spec := new(backend.Config)
+		return spec, json.Unmarshal(data, spec)
+	}
+	conf, err := parseFile(c, file)
+	if err != nil {
+		return nil, err
+	}
+	compiler, err := compilerFromContext(c, file)
+	if err != nil {
+		return nil, err
+	}
+	return compiler.Compile(conf)
+}
+
+// loadPipelines compiles every pipeline of the yaml file.
+func loadPipelines(c *cli.Context, file string) (map[bitbucket.Selector]*backend.Config, error) {
+	conf, err := parseFile(c, file)
+	if err != nil {
+		return nil, err
+	}
+	compiler, err := compilerFromContext(c, file)
+	if err != nil {
+		return nil, err
+	}
+	return compiler.CompileAll(conf)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/cncd/bitbucket-frontend/runner"
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/backend/docker"
+
+	"github.com/urfave/cli"
+)
+
+var execCommand = cli.Command{
+	Name:   "exec",
+	Usage:  "compile and execute the yaml file",
+	Action: execAction,
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "in",
+			Value: "bitbucket-pipelines.yml",
+		},
+		cli.StringFlag{
+			Name:  "engine",
+			Value: "docker",
+			Usage: "engine used to execute the pipeline (docker, fake)",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "print the steps that would run without running them",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Value: time.Hour,
+			Usage: "pipeline execution timeout",
+		},
+	}, compilerFlags...),
+}
+
+func execAction(c *cli.Context) error {
+	file, conf, err := parseFromContext(c)
+	if err != nil {
+		return err
+	}
+	compiler, err := compilerFromContext(c, file)
+	if err != nil {
+		return err
+	}
+	compiled, err := compiler.Compile(conf)
+	if err != nil {
+		return err
+	}
+
+	engine, err := engineFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Duration("timeout"))
+	defer cancel()
+
+	// cancel the pipeline on interrupt so running steps are killed
+	// and the pipeline is torn down.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	results, err := runner.New(engine,
+		runner.WithOutput(os.Stdout),
+		runner.WithSecrets(secretsFromContext(c)),
+	).Run(ctx, compiled)
+
+	if fake, ok := engine.(*runner.FakeEngine); ok && c.Bool("dry-run") {
+		fmt.Fprint(os.Stdout, fake)
+		return err
+	}
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			fmt.Fprintf(os.Stdout, "%s: skipped\n", result.Name)
+		case result.Error != nil:
+			fmt.Fprintf(os.Stdout, "%s: error: %s\n", result.Name, result.Error)
+		default:
+			fmt.Fprintf(os.Stdout, "%s: exit code %d\n", result.Name, result.ExitCode)
+		}
+	}
+	return err
+}
+
+// engineFromContext returns the engine selected by the command line
+// flags. A dry run always uses the fake engine.
+func engineFromContext(c *cli.Context) (backend.Engine, error) {
+	if c.Bool("dry-run") {
+		return runner.NewFakeEngine(), nil
+	}
+	switch name := c.String("engine"); name {
+	case "docker":
+		return docker.NewEnv()
+	case "fake":
+		return runner.NewFakeEngine(), nil
+	default:
+		return nil, fmt.Errorf("unsupported engine %q", name)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/cncd/bitbucket-frontend/rpc"
+
+	"github.com/urfave/cli"
+	"google.golang.org/grpc"
+)
+
+var grpcCommand = cli.Command{
+	Name:   "grpc",
+	Usage:  "serve the compiler over grpc",
+	Action: grpcAction,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:   "addr",
+			Value:  ":9090",
+			Usage:  "address the server listens on",
+			EnvVar: "BITBUCKETC_GRPC_ADDR",
+		},
+		cli.StringFlag{
+			Name:  "imports",
+			Usage: "directory containing repositories with exported pipelines",
+		},
+	},
+}
+
+func grpcAction(c *cli.Context) error {
+	lis, err := net.Listen("tcp", c.String("addr"))
+	if err != nil {
+		return err
+	}
+
+	var opts []rpc.Option
+	if resolver := resolverFromContext(c); resolver != nil {
+		opts = append(opts, rpc.WithResolver(resolver))
+	}
+	srv := grpc.NewServer()
+	rpc.New(opts...).Register(srv)
+
+	// stop gracefully on interrupt, letting in-flight
+	// requests complete.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		srv.GracefulStop()
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening on %s\n", lis.Addr())
+	return srv.Serve(lis)
+}
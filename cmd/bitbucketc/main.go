@@ -15,6 +15,14 @@ func main() {
 	app.Usage = "bitbucketc provides command line tools for bitbucket pipelines"
 	app.Commands = []cli.Command{
 		compileCommand,
+		convertCommand,
+		execCommand,
+		planCommand,
+		diffCommand,
+		serveCommand,
+		grpcCommand,
+		schemaCommand,
+		testCommand,
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cncd/bitbucket-frontend"
+
+	"github.com/urfave/cli"
+)
+
+var planCommand = cli.Command{
+	Name:   "plan",
+	Usage:  "explain which pipeline is selected and what it runs",
+	Action: planAction,
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "in",
+			Value: "bitbucket-pipelines.yml",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format (text, json)",
+		},
+		cli.BoolFlag{
+			Name:  "script",
+			Usage: "print the decoded build script of each step",
+		},
+	}, compilerFlags...),
+}
+
+func planAction(c *cli.Context) error {
+	file, conf, err := parseFromContext(c)
+	if err != nil {
+		return err
+	}
+	compiler, err := compilerFromContext(c, file)
+	if err != nil {
+		return err
+	}
+	plan, err := compiler.Plan(conf)
+	if err != nil {
+		return err
+	}
+
+	switch format := c.String("format"); format {
+	case "json":
+		out, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(os.Stdout, "%s\n", out)
+		return err
+	case "text":
+		writePlan(os.Stdout, plan, c.Bool("script"))
+		return nil
+	default:
+		return fmt.Errorf("unsupported plan format %q", format)
+	}
+}
+
+// writePlan writes the plan in a format readable in a terminal.
+func writePlan(w io.Writer, plan *bitbucket.Plan, script bool) {
+	fmt.Fprintf(w, "ref:    %s\n", plan.Ref)
+	fmt.Fprintf(w, "branch: %s\n", plan.Branch)
+	fmt.Fprintf(w, "\nselected pipeline: %s\n", plan.Selector)
+	for _, candidate := range plan.Candidates {
+		switch {
+		case candidate.Selector == plan.Selector:
+			fmt.Fprintf(w, "  * %s (selected)\n", candidate.Selector)
+		case candidate.Matched:
+			fmt.Fprintf(w, "  - %s (matched, lower precedence)\n", candidate.Selector)
+		default:
+			fmt.Fprintf(w, "  - %s (no match)\n", candidate.Selector)
+		}
+	}
+	if plan.Selector.Section == bitbucket.SectionDefault {
+		fmt.Fprintf(w, "  * default (no tag or branch pattern matched)\n")
+	}
+
+	fmt.Fprintf(w, "\nworkspace: %s\n", plan.Workspace)
+	fmt.Fprintf(w, "volumes:\n")
+	for _, volume := range plan.Volumes {
+		fmt.Fprintf(w, "  - %s\n", volume)
+	}
+	if plan.Clone != nil {
+		fmt.Fprintf(w, "clone:     %s (depth %d)\n", plan.Clone.Image, plan.Clone.Depth)
+	} else {
+		fmt.Fprintf(w, "clone:     disabled\n")
+	}
+
+	for _, step := range plan.Steps {
+		name := step.Alias
+		if step.Name != "" {
+			name += " " + step.Name
+		}
+		fmt.Fprintf(w, "\n%s\n", name)
+		fmt.Fprintf(w, "  image:      %s (%s)\n", step.Image, step.Platform)
+		if step.Deployment != "" {
+			fmt.Fprintf(w, "  deployment: %s\n", step.Deployment)
+		}
+		switch {
+		case step.Skipped:
+			fmt.Fprintf(w, "  trigger:    manual (not compiled, use --manual to include)\n")
+		case step.Manual:
+			fmt.Fprintf(w, "  trigger:    manual\n")
+		}
+		if script && step.Script != "" {
+			fmt.Fprintf(w, "  script:\n")
+			for _, line := range strings.Split(strings.TrimSpace(step.Script), "\n") {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "  commands:\n")
+		for _, command := range step.Commands {
+			fmt.Fprintf(w, "    %s\n", command)
+		}
+	}
+}
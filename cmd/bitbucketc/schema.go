@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cncd/bitbucket-frontend"
+	"github.com/cncd/bitbucket-frontend/schema"
+	"github.com/cncd/pipeline/pipeline/frontend"
+
+	"github.com/urfave/cli"
+)
+
+var schemaCommand = cli.Command{
+	Name:   "schema",
+	Usage:  "print the json schema of the supported bitbucket-pipelines.yml subset",
+	Action: configSchemaAction,
+	Subcommands: []cli.Command{
+		{
+			Name:   "metadata",
+			Usage:  "print the json schema of the metadata file",
+			Action: metadataSchemaAction,
+		},
+	},
+}
+
+func configSchemaAction(c *cli.Context) error {
+	return writeSchema(bitbucket.Schema())
+}
+
+func metadataSchemaAction(c *cli.Context) error {
+	out := schema.Generate(frontend.Metadata{})
+	out.Title = "bitbucketc metadata"
+	out.Description = "Build metadata read with the --metadata flag."
+	return writeSchema(out)
+}
+
+// writeSchema writes the indented schema to stdout.
+func writeSchema(s *schema.Schema) error {
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(os.Stdout, "%s\n", out)
+	return err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/cncd/bitbucket-frontend/server"
+
+	"github.com/urfave/cli"
+)
+
+var serveCommand = cli.Command{
+	Name:   "serve",
+	Usage:  "serve the compiler over http",
+	Action: serveAction,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:   "addr",
+			Value:  ":8080",
+			Usage:  "address the server listens on",
+			EnvVar: "BITBUCKETC_ADDR",
+		},
+		cli.Int64Flag{
+			Name:  "max-bytes",
+			Value: server.DefaultMaxBytes,
+			Usage: "maximum size of a request body",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Value: server.DefaultTimeout,
+			Usage: "maximum duration of a request",
+		},
+		cli.StringFlag{
+			Name:  "imports",
+			Usage: "directory containing repositories with exported pipelines",
+		},
+	},
+}
+
+func serveAction(c *cli.Context) error {
+	opts := []server.Option{
+		server.WithMaxBytes(c.Int64("max-bytes")),
+		server.WithTimeout(c.Duration("timeout")),
+	}
+	if resolver := resolverFromContext(c); resolver != nil {
+		opts = append(opts, server.WithResolver(resolver))
+	}
+
+	timeout := c.Duration("timeout")
+	srv := &http.Server{
+		Addr:         c.String("addr"),
+		Handler:      server.New(opts...).Handler(),
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout + time.Second,
+	}
+
+	// shutdown gracefully on interrupt, letting in-flight
+	// requests complete.
+	done := make(chan error, 1)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		done <- srv.Shutdown(ctx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening on %s\n", srv.Addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return <-done
+}
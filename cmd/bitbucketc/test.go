@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cncd/bitbucket-frontend"
+
+	"github.com/joho/godotenv"
+	"github.com/urfave/cli"
+)
+
+// files of a fixture directory.
+const (
+	fixtureConfig   = "bitbucket-pipelines.yml"
+	fixtureEnv      = ".env"
+	fixtureExpected = "pipeline.json"
+)
+
+var testCommand = cli.Command{
+	Name:      "test",
+	Usage:     "compile the fixtures in the directories and compare them to the expected pipelines",
+	ArgsUsage: "[dir...]",
+	Action:    testAction,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "update",
+			Usage: "regenerate the expected pipelines",
+		},
+		cli.IntFlag{
+			Name:  "context",
+			Value: 3,
+			Usage: "number of unchanged lines shown around each difference",
+		},
+	},
+}
+
+// testAction compiles every fixture directory, which contains a yaml
+// file and an environment file with the metadata, and compares the
+// compiled pipeline to the expected pipeline of the fixture.
+func testAction(c *cli.Context) error {
+	dirs := c.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	var fixtures []string
+	for _, dir := range dirs {
+		found, err := findFixtures(dir)
+		if err != nil {
+			return err
+		}
+		fixtures = append(fixtures, found...)
+	}
+	if len(fixtures) == 0 {
+		return fmt.Errorf("no fixtures found in %s", strings.Join(dirs, ", "))
+	}
+
+	failed := 0
+	for _, dir := range fixtures {
+		got, err := compileFixture(dir)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stdout, "FAIL %s\n  %s\n", dir, err)
+			continue
+		}
+
+		expected := filepath.Join(dir, fixtureExpected)
+		want, err := ioutil.ReadFile(expected)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		switch {
+		case bytes.Equal(want, got):
+			fmt.Fprintf(os.Stdout, "ok   %s\n", dir)
+		case c.Bool("update"):
+			if err := ioutil.WriteFile(expected, got, 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stdout, "updated %s\n", expected)
+		case want == nil:
+			failed++
+			fmt.Fprintf(os.Stdout, "FAIL %s\n  missing %s, run with --update to create it\n", dir, fixtureExpected)
+		default:
+			failed++
+			fmt.Fprintf(os.Stdout, "FAIL %s\n", dir)
+			for _, line := range bitbucket.DiffText(want, got, c.Int("context")) {
+				fmt.Fprintf(os.Stdout, "  %s\n", line)
+			}
+		}
+	}
+	if failed != 0 {
+		return cli.NewExitError(fmt.Sprintf("%d of %d fixtures failed", failed, len(fixtures)), 1)
+	}
+	return nil
+}
+
+// findFixtures returns the directories under the root that contain a
+// yaml file and an environment file. Hidden directories are skipped.
+func findFixtures(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		for _, name := range []string{fixtureConfig, fixtureEnv} {
+			if _, err := os.Stat(filepath.Join(path, name)); err != nil {
+				return nil
+			}
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return dirs, err
+}
+
+// fixtureFiles maps the flags that take file paths to the variables of
+// the environment file setting them. The paths are relative to the
+// fixture directory.
+var fixtureFiles = map[string]string{
+	"imports":             "CI_IMPORTS",
+	"workspace-env-file":  "CI_WORKSPACE_ENV_FILE",
+	"env-file":            "CI_ENV_FILE",
+	"deployment-env-file": "CI_DEPLOYMENT_ENV_FILE",
+	"metadata":            "CI_METADATA",
+	"webhook":             "CI_WEBHOOK",
+}
+
+// compileFixture compiles the yaml file of the fixture with the compiler
+// flags read from its environment file, and returns the json output.
+func compileFixture(dir string) ([]byte, error) {
+	env, err := godotenv.Read(filepath.Join(dir, fixtureEnv))
+	if err != nil {
+		return nil, err
+	}
+	c, err := fixtureContext(dir, env)
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Join(dir, fixtureConfig)
+	conf, err := parseFile(c, file)
+	if err != nil {
+		return nil, err
+	}
+	compiler, err := compilerFromContext(c, file)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := compiler.Compile(conf)
+	if err != nil {
+		return nil, err
+	}
+	return bitbucket.Marshal(compiled, bitbucket.FormatJSON)
+}
+
+// fixtureContext returns a context in which the compiler flags are set
+// from the variables of the environment file only, so that the result
+// does not depend on the environment of the process. File paths are
+// resolved against the fixture directory.
+func fixtureContext(dir string, env map[string]string) (*cli.Context, error) {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range compilerFlags {
+		keys, err := applyFixtureFlag(set, f)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSpace(strings.Split(f.GetName(), ",")[0])
+		if key, ok := fixtureFiles[name]; ok {
+			keys = append(keys, key)
+		}
+
+		// the first variable of the flag that is set is used, as
+		// for the environment variables of the process.
+		for _, key := range keys {
+			value, ok := env[key]
+			if !ok {
+				continue
+			}
+			values := []string{value}
+			if _, ok := f.(cli.StringSliceFlag); ok {
+				values = strings.Split(value, ",")
+			}
+			for _, value := range values {
+				if _, ok := fixtureFiles[name]; ok {
+					value = fixturePath(dir, name, value)
+				}
+				if err := set.Set(name, value); err != nil {
+					return nil, fmt.Errorf("invalid %s: %s", key, err)
+				}
+			}
+			break
+		}
+	}
+	return cli.NewContext(nil, set, nil), nil
+}
+
+// applyFixtureFlag applies a copy of the flag to the flag set that is
+// not read from the environment of the process, and returns the
+// environment variables of the flag.
+func applyFixtureFlag(set *flag.FlagSet, f cli.Flag) ([]string, error) {
+	var envVar string
+	switch f := f.(type) {
+	case cli.StringFlag:
+		envVar, f.EnvVar = f.EnvVar, ""
+		f.Apply(set)
+	case cli.StringSliceFlag:
+		// the default values are copied so that values set by a
+		// fixture are not appended to the defaults of the flag.
+		if f.Value != nil {
+			value := append(cli.StringSlice(nil), *f.Value...)
+			f.Value = &value
+		}
+		envVar, f.EnvVar = f.EnvVar, ""
+		f.Apply(set)
+	case cli.BoolFlag:
+		envVar, f.EnvVar = f.EnvVar, ""
+		f.Apply(set)
+	case cli.IntFlag:
+		envVar, f.EnvVar = f.EnvVar, ""
+		f.Apply(set)
+	case cli.Int64Flag:
+		envVar, f.EnvVar = f.EnvVar, ""
+		f.Apply(set)
+	default:
+		return nil, fmt.Errorf("unsupported flag %s", f.GetName())
+	}
+
+	var keys []string
+	for _, key := range strings.Split(envVar, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// fixturePath resolves the path set by the environment file of the
+// fixture against the fixture directory. Deployment env files are in
+// the format name=file.
+func fixturePath(dir, name, value string) string {
+	if name == "deployment-env-file" {
+		if parts := strings.SplitN(value, "=", 2); len(parts) == 2 {
+			return parts[0] + "=" + fixturePath(dir, "", parts[1])
+		}
+		return value
+	}
+	if value == "" || value == "-" || filepath.IsAbs(value) {
+		return value
+	}
+	return filepath.Join(dir, value)
+}
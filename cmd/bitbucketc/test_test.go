@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindFixtures(t *testing.T) {
+	root, err := ioutil.TempDir("", "fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, name := range []string{
+		"a/bitbucket-pipelines.yml", "a/.env",
+		"b/nested/bitbucket-pipelines.yml", "b/nested/.env",
+		"c/bitbucket-pipelines.yml",
+		".hidden/bitbucket-pipelines.yml", ".hidden/.env",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dirs, err := findFixtures(root)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := []string{filepath.Join(root, "a"), filepath.Join(root, "b", "nested")}
+	if strings.Join(dirs, ",") != strings.Join(want, ",") {
+		t.Errorf("Wanted fixtures %q, got %q", want, dirs)
+	}
+}
+
+func TestFixtureContext(t *testing.T) {
+	os.Setenv("CI_BUILD_NUMBER", "99")
+	os.Setenv("CI_COMMIT_BRANCH", "develop")
+	defer os.Unsetenv("CI_BUILD_NUMBER")
+	defer os.Unsetenv("CI_COMMIT_BRANCH")
+
+	dir := filepath.Join("testdata", "fixture")
+	c, err := fixtureContext(dir, map[string]string{
+		"CI_BUILD_NUMBER":        "6",
+		"CI_REPO_PRIVATE":        "true",
+		"CI_ENV_FILE":            "repo.env,/etc/shared.env",
+		"CI_DEPLOYMENT_ENV_FILE": "staging=staging.env",
+		"CI_METADATA":            "-",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if want, got := 6, c.Int("build-number"); want != got {
+		t.Errorf("Wanted build number %d, got %d", want, got)
+	}
+	if !c.Bool("repo-private") {
+		t.Errorf("Expect repo-private set from the environment file")
+	}
+	if got := c.String("commit-branch"); got != "" {
+		t.Errorf("Expect the environment of the process ignored, got branch %q", got)
+	}
+	if want, got := "pipeline", c.String("prefix"); want != got {
+		t.Errorf("Wanted default prefix %q, got %q", want, got)
+	}
+	if want, got := 3, len(c.StringSlice("privileged")); want != got {
+		t.Errorf("Wanted %d default privileged images, got %d", want, got)
+	}
+
+	want := []string{filepath.Join(dir, "repo.env"), "/etc/shared.env"}
+	if got := c.StringSlice("env-file"); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Wanted env files %q, got %q", want, got)
+	}
+	if want, got := "staging="+filepath.Join(dir, "staging.env"), c.StringSlice("deployment-env-file")[0]; want != got {
+		t.Errorf("Wanted deployment env file %q, got %q", want, got)
+	}
+	if want, got := "-", c.String("metadata"); want != got {
+		t.Errorf("Wanted metadata %q, got %q", want, got)
+	}
+}
+
+func TestFixtureContextInvalid(t *testing.T) {
+	_, err := fixtureContext(".", map[string]string{"CI_BUILD_NUMBER": "six"})
+	if err == nil {
+		t.Errorf("Expect error for an invalid build number")
+	}
+}
+
+func TestCompileFixture(t *testing.T) {
+	got, err := compileFixture(filepath.Join("testdata", "fixture"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, want := range []string{
+		`"CI_BUILD_NUMBER": "6"`,
+		`"CI_REPO_NAME": "octocat/hello-world"`,
+		`"API_URL": "repository"`,
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("Expect compiled pipeline to contain %s", want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/cncd/bitbucket-frontend"
+
+	"github.com/urfave/cli"
+)
+
+// watchAction compiles the yaml file and recompiles it each time the
+// yaml file, one of the imported files or one of the variable and
+// metadata files changes, until interrupted.
+func watchAction(c *cli.Context) error {
+	if c.String("hook") != "" && !c.Bool("local") {
+		return errors.New("the hook flag requires the local flag")
+	}
+	interval := c.Duration("watch-interval")
+	if interval <= 0 {
+		return cli.NewExitError(fmt.Sprintf("invalid watch interval %s, the interval must be positive", interval), 1)
+	}
+
+	file := c.Args().First()
+	if file == "" {
+		file = c.String("in")
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		files := watchCompile(c, file)
+		snapshot := statFiles(files)
+
+		fmt.Fprintf(os.Stderr, "Watching %d files for changes\n", len(files))
+	wait:
+		for {
+			select {
+			case <-sig:
+				return nil
+			case <-ticker.C:
+				if statFiles(files) != snapshot {
+					break wait
+				}
+			}
+		}
+	}
+}
+
+// watchCompile compiles the yaml file, printing errors and lint
+// diagnostics, and runs the hook if the compile succeeds. It returns
+// the files read while compiling, including the imported files and
+// the files of the variable and metadata flags.
+func watchCompile(c *cli.Context, file string) []string {
+	fmt.Fprintf(os.Stderr, "[%s] Compiling %s\n", time.Now().Format("15:04:05"), file)
+
+	var opts []bitbucket.ParseOption
+	tracker := &trackingResolver{}
+	if resolver := resolverFromContext(c); resolver != nil {
+		tracker.FileResolver = resolver
+		opts = append(opts, bitbucket.WithResolver(tracker))
+	}
+	files := append([]string{file}, inputFiles(c)...)
+
+	conf, err := bitbucket.ParseFile(file, opts...)
+	files = append(files, tracker.paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return files
+	}
+	for _, diagnostic := range bitbucket.Lint(conf) {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", diagnostic)
+	}
+	if err := writeCompiled(c, file, conf); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return files
+	}
+
+	if hook := c.String("hook"); hook != "" {
+		cmd := exec.Command("/bin/sh", "-c", hook)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "hook: %s\n", err)
+		}
+	}
+	return files
+}
+
+// inputFiles returns the files of the variable and metadata flags read
+// by the compiler. Input read from stdin is not watched.
+func inputFiles(c *cli.Context) []string {
+	var files []string
+	files = append(files, c.StringSlice("workspace-env-file")...)
+	files = append(files, c.StringSlice("env-file")...)
+	for _, value := range c.StringSlice("deployment-env-file") {
+		if parts := strings.SplitN(value, "=", 2); len(parts) == 2 {
+			files = append(files, parts[1])
+		}
+	}
+	for _, name := range []string{"metadata", "webhook"} {
+		if path := c.String(name); path != "" && path != "-" {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// trackingResolver is a file resolver that records the path of every
+// resolved configuration file so the imported files can be watched.
+type trackingResolver struct {
+	*bitbucket.FileResolver
+	paths []string
+}
+
+// Resolve reads the configuration file and records its path.
+func (r *trackingResolver) Resolve(repo, ref string) ([]byte, error) {
+	r.paths = append(r.paths, r.Path(repo, ref))
+	return r.FileResolver.Resolve(repo, ref)
+}
+
+// statFiles returns a snapshot of the size and modification time of
+// the files. Files that do not exist are included in the snapshot so
+// that their creation is detected.
+func statFiles(files []string) string {
+	var snapshot string
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			snapshot += file + ":missing\n"
+			continue
+		}
+		snapshot += fmt.Sprintf("%s:%d:%d\n", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return snapshot
+}
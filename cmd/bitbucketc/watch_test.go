@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInputFiles(t *testing.T) {
+	c, err := fixtureContext(".", map[string]string{
+		"CI_WORKSPACE_ENV_FILE":  "workspace.env",
+		"CI_ENV_FILE":            "repo.env,shared.env",
+		"CI_DEPLOYMENT_ENV_FILE": "staging=staging.env",
+		"CI_METADATA":            "metadata.json",
+		"CI_WEBHOOK":             "-",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := "workspace.env,repo.env,shared.env,staging.env,metadata.json"
+	if got := strings.Join(inputFiles(c), ","); want != got {
+		t.Errorf("Wanted watched files %q, got %q", want, got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -15,19 +16,30 @@ import (
 
 // Compiler compiles the yaml
 type Compiler struct {
-	local   bool
-	prefix  string
-	volumes []string
-	env     map[string]string
-	base    string
-	path    string
-	meta    frontend.Metadata
+	local       bool
+	manual      bool
+	pipefail    bool
+	markers     bool
+	shell       string
+	prefix      string
+	volumes     []string
+	env         map[string]string
+	overrides   map[string]string
+	secrets     map[string]string
+	deployments map[string]map[string]string
+	base        string
+	path        string
+	meta        frontend.Metadata
+	transforms  []Transform
 }
 
 // NewCompiler creates a new Compiler with options.
 func NewCompiler(opts ...Option) *Compiler {
 	compiler := new(Compiler)
 	compiler.env = map[string]string{}
+	compiler.overrides = map[string]string{}
+	compiler.deployments = map[string]map[string]string{}
+	compiler.secrets = map[string]string{}
 	compiler.base = "/workspace"
 	compiler.path = "src"
 	for _, opt := range opts {
@@ -38,14 +50,49 @@ func NewCompiler(opts ...Option) *Compiler {
 }
 
 // Compile compiles the YAML configuration to the pipeline intermediate
-// representation configuration format.
-func (c *Compiler) Compile(conf *Config) *backend.Config {
-	spec := new(backend.Config)
-
+// representation configuration format. The transforms of the compiler
+// are applied to the compiled configuration in order.
+func (c *Compiler) Compile(conf *Config) (*backend.Config, error) {
 	// choose which pipeline to execute
 	// return the pipeline by name
 	section := conf.Pipeline(c.meta.Curr.Commit.Ref, c.meta.Curr.Commit.Branch)
 
+	return c.transform(c.compile(conf, section), conf)
+}
+
+// CompileAll compiles every pipeline in the YAML configuration to the
+// pipeline intermediate representation configuration format, keyed by
+// the selector of the pipeline. The transforms of the compiler are
+// applied to each compiled configuration in order.
+func (c *Compiler) CompileAll(conf *Config) (map[Selector]*backend.Config, error) {
+	compiled := map[Selector]*backend.Config{}
+	for _, selector := range conf.Selectors() {
+		section, _ := conf.Lookup(selector)
+		spec, err := c.transform(c.compile(conf, section), conf)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", selector, err)
+		}
+		compiled[selector] = spec
+	}
+	return compiled, nil
+}
+
+// transform applies the transforms to the compiled configuration in
+// order, stopping at the first error.
+func (c *Compiler) transform(spec *backend.Config, conf *Config) (*backend.Config, error) {
+	for _, transform := range c.transforms {
+		if err := transform(spec, conf, c.meta); err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
+}
+
+// compile compiles the pipeline stage to the pipeline intermediate
+// representation configuration format.
+func (c *Compiler) compile(conf *Config, section Stage) *backend.Config {
+	spec := new(backend.Config)
+
 	// defines the default workspace
 	workingdir := path.Join(c.base, c.path)
 
@@ -55,6 +102,12 @@ func (c *Compiler) Compile(conf *Config) *backend.Config {
 	volume.Name = fmt.Sprintf("%s_workspace", c.prefix)
 	spec.Volumes = append(spec.Volumes, volume)
 
+	// declares the secrets by name. The runtime injects the
+	// secret values and masks them in the build logs.
+	for _, name := range c.secretNames() {
+		spec.Secrets = append(spec.Secrets, &backend.Secret{Name: name})
+	}
+
 	// create the default volume reference.
 	volumes := []string{
 		volume.Name + ":" + c.base,
@@ -63,14 +116,18 @@ func (c *Compiler) Compile(conf *Config) *backend.Config {
 
 	// adds the default clone stage
 	if c.local == false {
-		envs := copyEnv(c.env)
+		envs := c.stepEnv(nil)
 		envs["PLUGIN_DEPTH"] = strconv.Itoa(conf.Clone.Depth)
 
+		// the clone step is scheduled on the platform of the
+		// system, like the steps without a runtime architecture.
+		platform := c.platform()
 		step := &backend.Step{
 			Name:        fmt.Sprintf("%s_clone", c.prefix),
 			Alias:       "clone",
-			Image:       "plugins/git:latest",
+			Image:       cloneImage(platform),
 			Environment: envs,
+			Labels:      map[string]string{"platform": platform},
 			OnSuccess:   true,
 			OnFailure:   false,
 			Volumes:     volumes,
@@ -87,24 +144,36 @@ func (c *Compiler) Compile(conf *Config) *backend.Config {
 
 	// adds the pipeline steps
 	for i, step := range section.Steps {
-		image := step.Image
-		if image == "" {
-			image = conf.Image
+		// stop at the first manual trigger unless manual
+		// steps are explicitly included.
+		if section.Gated(i) && c.manual == false {
+			break
 		}
-		image = expandImage(image)
 
-		envs := copyEnv(c.env)
-		envs["CI_SCRIPT"] = toScript(step.Script)
+		image := stepImage(conf, step)
+		platform := c.platformFor(step)
+
+		envs := c.stepEnv(c.deployments[step.Target()])
+		envs["CI_SYSTEM_ARCH"] = platform
+		envs["DRONE_ARCH"] = platform
+		envs["CI_SCRIPT"] = toScript(step.Script, scriptOptions{
+			pipefail: c.pipefail,
+			markers:  c.markers,
+			secrets:  c.secretValues(),
+		})
 		envs["HOME"] = "/root"
-		envs["SHELL"] = "/bin/sh"
+		if c.shell != "" {
+			envs["SHELL"] = c.shell
+		}
 
 		step := &backend.Step{
 			Name:        fmt.Sprintf("%s_step_%d", c.prefix, i),
 			Alias:       fmt.Sprintf("step_%d", i),
 			Image:       image,
 			Environment: envs,
+			Labels:      map[string]string{"platform": platform},
 			Entrypoint:  []string{"/bin/sh", "-c"},
-			Command:     []string{"echo $CI_SCRIPT | base64 -d | /bin/sh -e"},
+			Command:     []string{shellCommand(c.shell)},
 			Volumes:     volumes,
 			WorkingDir:  workingdir,
 			OnSuccess:   true,
@@ -122,6 +191,66 @@ func (c *Compiler) Compile(conf *Config) *backend.Config {
 	return spec
 }
 
+// platform returns the platform of the system running the pipeline.
+func (c *Compiler) platform() string {
+	if c.meta.Sys.Arch == "" {
+		return "linux/amd64"
+	}
+	return c.meta.Sys.Arch
+}
+
+// platformFor returns the platform of the step. Steps that define the
+// runtime architecture run on the matching linux platform, other steps
+// run on the platform of the system.
+func (c *Compiler) platformFor(step *Step) string {
+	switch step.Runtime.Cloud.Arch {
+	case ArchARM:
+		return "linux/arm64"
+	case ArchX86:
+		return "linux/amd64"
+	default:
+		return c.platform()
+	}
+}
+
+// stepEnv returns the environment of a step with the additional
+// variables, followed by the overrides. Secrets are excluded from the
+// environment.
+func (c *Compiler) stepEnv(extra map[string]string) map[string]string {
+	envs := copyEnv(c.env)
+	for k, v := range extra {
+		envs[k] = v
+	}
+	for k, v := range c.overrides {
+		envs[k] = v
+	}
+	for name := range c.secrets {
+		delete(envs, name)
+	}
+	return envs
+}
+
+// secretNames returns the sorted names of the secrets.
+func (c *Compiler) secretNames() []string {
+	var names []string
+	for name := range c.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// secretValues returns the non-empty values of the secrets.
+func (c *Compiler) secretValues() []string {
+	var values []string
+	for _, name := range c.secretNames() {
+		if value := c.secrets[name]; value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func copyEnv(from map[string]string) map[string]string {
 	to := map[string]string{}
 	for k, v := range from {
@@ -130,6 +259,26 @@ func copyEnv(from map[string]string) map[string]string {
 	return to
 }
 
+// cloneImage returns the variant of the clone plugin image for the
+// platform. Plugin images for platforms other than linux/amd64 are
+// tagged with the operating system and architecture.
+func cloneImage(platform string) string {
+	if platform == "linux/amd64" {
+		return "plugins/git:latest"
+	}
+	return "plugins/git:" + strings.Replace(platform, "/", "-", -1)
+}
+
+// stepImage returns the fully qualified image of the step, falling
+// back to the global image of the configuration.
+func stepImage(conf *Config, step *Step) string {
+	image := step.Image
+	if image == "" {
+		image = conf.Image
+	}
+	return expandImage(image)
+}
+
 func expandImage(name string) string {
 	ref, err := reference.ParseNamed(name)
 	if err != nil {
@@ -138,11 +287,45 @@ func expandImage(name string) string {
 	return reference.WithDefaultTag(ref).String()
 }
 
-func toScript(commands []string) string {
+// shellCommand returns the command that decodes and executes the build
+// script with the shell. If the shell is empty the script is executed
+// with bash when the image provides it, as in Bitbucket, and with sh
+// otherwise.
+func shellCommand(shell string) string {
+	if shell == "" {
+		return autoShellCommand
+	}
+	return fmt.Sprintf("echo $CI_SCRIPT | base64 -d | %s -e", shell)
+}
+
+// scriptOptions configures the generated build script.
+type scriptOptions struct {
+	pipefail bool
+	markers  bool
+	secrets  []string
+}
+
+func toScript(commands []string, opts scriptOptions) string {
 	var buf bytes.Buffer
-	for _, command := range commands {
-		escaped := fmt.Sprintf("%q", command)
+	for i, command := range commands {
+		// secret values written literally in the command are
+		// masked in the trace output.
+		traced := command
+		for _, secret := range opts.secrets {
+			traced = strings.Replace(traced, secret, "********", -1)
+		}
+		escaped := fmt.Sprintf("%q", traced)
 		escaped = strings.Replace(escaped, "$", `\$`, -1)
+		if opts.markers {
+			buf.WriteString(fmt.Sprintf(
+				markerScript,
+				i, i,
+				escaped,
+				command,
+				i,
+			))
+			continue
+		}
 		buf.WriteString(fmt.Sprintf(
 			traceScript,
 			escaped,
@@ -150,8 +333,17 @@ func toScript(commands []string) string {
 		))
 	}
 
+	var options string
+	if opts.pipefail {
+		options += pipefailScript
+	}
+	if opts.markers {
+		options += markerSetupScript
+	}
+
 	script := fmt.Sprintf(
 		setupScript,
+		options,
 		buf.String(),
 	)
 
@@ -172,7 +364,36 @@ fi
 unset CI_NETRC_USERNAME
 unset CI_NETRC_PASSWORD
 unset CI_SCRIPT
+%s%s
+`
+
+// pipefailScript is a helper script that is added to the build script
+// to fail a pipeline when any command of the pipeline fails. The option
+// is tested in a subshell first because shells that do not support it,
+// such as dash, exit on the invalid option.
+const pipefailScript = `
+if (set -o pipefail) 2>/dev/null; then set -o pipefail; fi
+`
+
+// autoShellCommand is the command that executes the build script with
+// bash if the image provides it, falling back to sh.
+const autoShellCommand = `if [ -x /bin/bash ]; then export SHELL=/bin/bash; else export SHELL=/bin/sh; fi; echo $CI_SCRIPT | base64 -d | $SHELL -e`
+
+// markerSetupScript is a helper script that is added to the build script
+// to write the end marker of a command that exits the script.
+const markerSetupScript = `
+trap 'CI_TRACE_STATUS=$?; if [ -n "$CI_TRACE_INDEX" ]; then echo "` + markerPrefix + ` end $CI_TRACE_INDEX $CI_TRACE_STATUS $(date +%s)"; fi' EXIT
+`
+
+// markerScript is a helper script that is added to the build script
+// to trace a command with start and end markers.
+const markerScript = `
+CI_TRACE_INDEX=%d
+echo "` + markerPrefix + ` start %d $(date +%%s)"
+echo + %s
 %s
+echo "` + markerPrefix + ` end %d 0 $(date +%%s)"
+CI_TRACE_INDEX=
 `
 
 // traceScript is a helper script that is added to the build script
@@ -0,0 +1,258 @@
+package bitbucket
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+func TestCompileStageGroups(t *testing.T) {
+	config, err := ParseString(stageYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the pipeline stops before the manual stage
+	compiled, err := NewCompiler(WithPrefix("test"), WithLocal(true)).Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := 1, len(compiled.Stages); want != got {
+		t.Errorf("Wanted %d stages before the manual gate, got %d", want, got)
+	}
+
+	compiled, err = NewCompiler(
+		WithPrefix("test"),
+		WithLocal(true),
+		WithManual(true),
+		WithDeployment("staging", map[string]string{
+			"API_URL": "https://staging.example.com",
+		}),
+	).Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := 4, len(compiled.Stages); want != got {
+		t.Errorf("Wanted %d stages including manual steps, got %d", want, got)
+		t.FailNow()
+	}
+	for i, want := range []string{"", "https://staging.example.com", "https://staging.example.com", ""} {
+		got := compiled.Stages[i].Steps[0].Environment["API_URL"]
+		if got != want {
+			t.Errorf("Wanted stage %d API_URL %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestCompileAll(t *testing.T) {
+	config, err := ParseString(pipelineYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	compiled, err := NewCompiler(WithLocal(true)).CompileAll(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := 3, len(compiled); want != got {
+		t.Errorf("Wanted %d compiled pipelines, got %d", want, got)
+	}
+	for selector, steps := range map[Selector]int{
+		{Section: SectionDefault}:                      1,
+		{Section: SectionTags, Pattern: "release-*"}:   1,
+		{Section: SectionBranches, Pattern: "staging"}: 1,
+	} {
+		spec, ok := compiled[selector]
+		if !ok {
+			t.Errorf("Expect pipeline %s compiled", selector)
+			continue
+		}
+		if got := len(spec.Stages); got != steps {
+			t.Errorf("Wanted %d stages in %s, got %d", steps, selector, got)
+		}
+	}
+}
+
+func TestCompileShell(t *testing.T) {
+	config, err := ParseString(sample)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	compiled, err := NewCompiler(WithLocal(true)).Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	step := compiled.Stages[0].Steps[0]
+	if want, got := autoShellCommand, step.Command[0]; want != got {
+		t.Errorf("Wanted command %q, got %q", want, got)
+	}
+	if _, ok := step.Environment["SHELL"]; ok {
+		t.Errorf("Expect SHELL to be detected at runtime")
+	}
+
+	compiled, err = NewCompiler(WithLocal(true), WithShell("/bin/sh")).Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	step = compiled.Stages[0].Steps[0]
+	if want, got := "echo $CI_SCRIPT | base64 -d | /bin/sh -e", step.Command[0]; want != got {
+		t.Errorf("Wanted command %q, got %q", want, got)
+	}
+	if want, got := "/bin/sh", step.Environment["SHELL"]; want != got {
+		t.Errorf("Wanted SHELL %q, got %q", want, got)
+	}
+}
+
+func TestToScriptPipefail(t *testing.T) {
+	decode := func(s string) string {
+		out, _ := base64.StdEncoding.DecodeString(s)
+		return string(out)
+	}
+	if strings.Contains(decode(toScript([]string{"make"}, scriptOptions{})), "pipefail") {
+		t.Errorf("Expect pipefail disabled by default")
+	}
+	if !strings.Contains(decode(toScript([]string{"make"}, scriptOptions{pipefail: true})), "set -o pipefail") {
+		t.Errorf("Expect pipefail enabled in the script")
+	}
+}
+
+func TestCompileSecrets(t *testing.T) {
+	config, err := ParseString(sample)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	config.Pipelines.Default.Steps[0].Script = []string{
+		"curl -u admin:hunter2 https://example.com",
+	}
+
+	compiled, err := NewCompiler(
+		WithNetrc("octocat", "hunter2", "github.com"),
+		WithSecrets(map[string]string{
+			"CI_NETRC_PASSWORD":    "hunter2",
+			"DRONE_NETRC_PASSWORD": "hunter2",
+		}),
+	).Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if want, got := 2, len(compiled.Secrets); want != got {
+		t.Errorf("Wanted %d secrets, got %d", want, got)
+		t.FailNow()
+	}
+	if want, got := "CI_NETRC_PASSWORD", compiled.Secrets[0].Name; want != got {
+		t.Errorf("Wanted secret %s, got %s", want, got)
+	}
+
+	out, _ := json.Marshal(compiled)
+	if strings.Contains(string(out), "hunter2") {
+		t.Errorf("Expect secret value not visible in the compiled json")
+	}
+	for _, stage := range compiled.Stages {
+		for _, step := range stage.Steps {
+			if _, ok := step.Environment["CI_NETRC_PASSWORD"]; ok {
+				t.Errorf("Expect secret not in the environment of %s", step.Name)
+			}
+		}
+	}
+
+	script, _ := base64.StdEncoding.DecodeString(
+		compiled.Stages[1].Steps[0].Environment["CI_SCRIPT"],
+	)
+	if !strings.Contains(string(script), `echo + "curl -u admin:******** https://example.com"`) {
+		t.Errorf("Expect secret value masked in the trace output")
+	}
+}
+
+func TestCompilePlatform(t *testing.T) {
+	config, err := ParseString(archYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	metadata := frontend.Metadata{
+		Sys: frontend.System{Arch: "linux/arm"},
+	}
+	compiled, err := NewCompiler(WithMetadata(metadata)).Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if want, got := "plugins/git:linux-arm", compiled.Stages[0].Steps[0].Image; want != got {
+		t.Errorf("Wanted clone image %s, got %s", want, got)
+	}
+	if want, got := "linux/arm", compiled.Stages[0].Steps[0].Labels["platform"]; want != got {
+		t.Errorf("Wanted clone platform label %s, got %s", want, got)
+	}
+	for i, want := range []string{"linux/arm", "linux/arm64", "linux/amd64"} {
+		step := compiled.Stages[i+1].Steps[0]
+		if got := step.Labels["platform"]; got != want {
+			t.Errorf("Wanted step %d platform %s, got %s", i, want, got)
+		}
+		if got := step.Environment["CI_SYSTEM_ARCH"]; got != want {
+			t.Errorf("Wanted step %d CI_SYSTEM_ARCH %s, got %s", i, want, got)
+		}
+	}
+
+	compiled, err = NewCompiler().Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := "plugins/git:latest", compiled.Stages[0].Steps[0].Image; want != got {
+		t.Errorf("Wanted default clone image %s, got %s", want, got)
+	}
+}
+
+func TestCompilePlatformInvalid(t *testing.T) {
+	_, err := ParseString(`
+pipelines:
+  default:
+    - step:
+        runtime:
+          cloud:
+            arch: mips
+        script: [ make ]
+`)
+	if err == nil {
+		t.Errorf("Expect error for an unsupported runtime arch")
+	}
+}
+
+var archYaml = `
+image: golang:1.8
+
+pipelines:
+  default:
+    - step:
+        script:
+          - go build
+    - step:
+        runtime:
+          cloud:
+            arch: arm
+        script:
+          - go build
+    - step:
+        runtime:
+          cloud:
+            arch: x86
+        script:
+          - go build
+`
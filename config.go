@@ -1,7 +1,10 @@
 package bitbucket
 
 import (
+	"errors"
+	"fmt"
 	"path"
+	"sort"
 	"strings"
 )
 
@@ -22,12 +25,29 @@ type (
 
 		// Pipeline defines the pipeline configuration
 		// which includes a list of all steps for default,
-		// tag, and branch-specific execution.
+		// tag, branch, bookmark, pull request and custom
+		// execution.
 		Pipelines struct {
-			Default  Stage
-			Tags     map[string]Stage
-			Branches map[string]Stage
+			Default      Stage
+			Tags         map[string]Stage
+			Branches     map[string]Stage
+			Bookmarks    map[string]Stage
+			PullRequests map[string]Stage `yaml:"pull-requests"`
+			Custom       map[string]Stage
 		}
+
+		// Definitions defines reusable pipelines that
+		// other repositories can import by name, and the
+		// services and caches available to steps.
+		Definitions struct {
+			Pipelines map[string]Stage
+			Services  map[string]Service
+			Caches    map[string]string
+		}
+
+		// Export allows other repositories to import
+		// the pipelines in the definitions section.
+		Export bool
 	}
 
 	// Stage contains a list of steps executed
@@ -35,10 +55,41 @@ type (
 	Stage struct {
 		Name  string
 		Steps []*Step
+
+		// Import references a pipeline exported by another
+		// repository in the format repo:ref:name. The steps
+		// are populated when the import is resolved.
+		Import string
+	}
+
+	// Group defines a set of steps declared with the stage
+	// keyword. The steps in a group deploy together and share
+	// the deployment environment and trigger of the group.
+	Group struct {
+		Name       string
+		Deployment string
+		Trigger    string
+	}
+
+	// Artifacts lists the glob patterns of the files produced by
+	// a step. The patterns are declared as a list, or as the paths
+	// of an object that also sets whether the artifacts of the
+	// previous steps are downloaded.
+	Artifacts []string
+
+	// Service defines a service container that runs
+	// alongside the steps that reference it.
+	Service struct {
+		Image     string
+		Variables map[string]string
+		Memory    int
 	}
 
 	// Step defines a build execution unit.
 	Step struct {
+		// Name defines the display name of the step.
+		Name string
+
 		// Image specifies the Docker image with
 		// which we run your builds.
 		Image string
@@ -46,42 +97,394 @@ type (
 		// Script contains the list of bash commands
 		// that are executed in sequence.
 		Script []string
+
+		// Deployment defines the deployment environment
+		// to which the step deploys.
+		Deployment string
+
+		// Trigger defines whether the step runs automatically
+		// or must be triggered manually.
+		Trigger string
+
+		// Services lists the names of the services that
+		// run alongside the step.
+		Services []string
+
+		// Caches lists the names of the caches used by
+		// the step to persist dependencies between builds.
+		Caches []string
+
+		// Artifacts lists the glob patterns of the files
+		// produced by the step that are passed to the
+		// following steps.
+		Artifacts Artifacts
+
+		// Runtime defines the runtime environment of the
+		// step, such as the architecture of the runner.
+		Runtime struct {
+			Cloud struct {
+				Arch string
+			}
+		}
+
+		// Group is the stage group the step belongs to, or
+		// nil if the step is not declared inside a stage.
+		Group *Group `yaml:"-"`
+	}
+)
+
+// pipeline sections of the configuration.
+const (
+	SectionDefault      = "default"
+	SectionTags         = "tags"
+	SectionBranches     = "branches"
+	SectionBookmarks    = "bookmarks"
+	SectionPullRequests = "pull-requests"
+	SectionCustom       = "custom"
+)
+
+// Selector identifies a pipeline by the section of the configuration
+// and the pattern within the section. The pattern of the default
+// pipeline is empty.
+type Selector struct {
+	Section string
+	Pattern string
+}
+
+// ParseSelector parses a selector in the format section/pattern.
+func ParseSelector(s string) (Selector, error) {
+	if s == SectionDefault {
+		return Selector{Section: SectionDefault}, nil
 	}
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return Selector{}, fmt.Errorf("invalid pipeline selector %q", s)
+	}
+	switch parts[0] {
+	case SectionTags, SectionBranches, SectionBookmarks, SectionPullRequests, SectionCustom:
+		return Selector{Section: parts[0], Pattern: parts[1]}, nil
+	default:
+		return Selector{}, fmt.Errorf("invalid pipeline section %q", parts[0])
+	}
+}
+
+// String returns the selector in the format section/pattern.
+func (s Selector) String() string {
+	if s.Section == SectionDefault {
+		return s.Section
+	}
+	return s.Section + "/" + s.Pattern
+}
+
+// MarshalText implements encoding.TextMarshaler so that selectors
+// can be used as keys of json objects.
+func (s Selector) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Selector) UnmarshalText(text []byte) error {
+	out, err := ParseSelector(string(text))
+	if err != nil {
+		return err
+	}
+	*s = out
+	return nil
+}
+
+// runtime architectures supported by steps.
+const (
+	ArchX86 = "x86"
+	ArchARM = "arm"
+)
+
+// trigger values supported by steps and stage groups.
+const (
+	TriggerAutomatic = "automatic"
+	TriggerManual    = "manual"
 )
 
+// PredefinedCaches maps the names of the caches predefined by Bitbucket
+// to the directories they persist. The docker cache persists the image
+// layers of the docker service and has no directory in the container.
+var PredefinedCaches = map[string]string{
+	"composer":   "~/.composer/cache",
+	"docker":     "",
+	"dotnetcore": "~/.nuget/packages",
+	"gradle":     "~/.gradle/caches",
+	"ivy2":       "~/.ivy2/cache",
+	"maven":      "~/.m2/repository",
+	"node":       "node_modules",
+	"pip":        "~/.cache/pip",
+	"sbt":        "~/.sbt",
+}
+
+// Candidate is a pipeline pattern considered when selecting the
+// pipeline that matches a branch or tag.
+type Candidate struct {
+	Selector Selector `json:"selector"`
+	Matched  bool     `json:"matched"`
+}
+
 // Pipeline returns the pipeline stage that best matches the branch
 // and ref. If there is no matching pipeline specific to the branch
 // or tag, the default pipeline is returned.
 func (c *Config) Pipeline(ref, branch string) Stage {
-	// match pipeline by tag name
+	selector, _ := c.Match(ref, branch)
+	stage, _ := c.Lookup(selector)
+	return stage
+}
+
+// Match returns the selector of the pipeline that best matches the
+// branch and ref, and every tag and branch pattern considered. Tag
+// pipelines take precedence over branch pipelines, and within a
+// section a pattern equal to the name takes precedence over glob
+// patterns, which are matched in sorted order. If no pattern
+// matches, the default pipeline is selected.
+func (c *Config) Match(ref, branch string) (Selector, []*Candidate) {
+	var candidates []*Candidate
+	var selected *Selector
+
 	tag := strings.TrimPrefix(ref, "refs/tags/")
-	for pattern, pipeline := range c.Pipelines.Tags {
-		if ok, _ := path.Match(pattern, tag); ok {
-			return pipeline
+	for _, match := range []struct {
+		section string
+		name    string
+	}{
+		{SectionTags, tag},
+		{SectionBranches, branch},
+	} {
+		var patterns []string
+		for pattern := range c.sections()[match.section] {
+			patterns = append(patterns, pattern)
+		}
+		sort.Slice(patterns, func(i, j int) bool {
+			// the exact match is considered first.
+			if (patterns[i] == match.name) != (patterns[j] == match.name) {
+				return patterns[i] == match.name
+			}
+			return patterns[i] < patterns[j]
+		})
+		for _, pattern := range patterns {
+			candidate := &Candidate{
+				Selector: Selector{Section: match.section, Pattern: pattern},
+			}
+			candidate.Matched, _ = path.Match(pattern, match.name)
+			if candidate.Matched && selected == nil {
+				selected = &candidate.Selector
+			}
+			candidates = append(candidates, candidate)
 		}
 	}
-	// match pipeline by branch name
-	for pattern, pipeline := range c.Pipelines.Branches {
-		if ok, _ := path.Match(pattern, branch); ok {
-			return pipeline
+	if selected == nil {
+		return Selector{Section: SectionDefault}, candidates
+	}
+	return *selected, candidates
+}
+
+// Target returns the deployment environment of the step. Steps
+// declared inside a stage inherit the deployment of the stage.
+func (s *Step) Target() string {
+	if s.Group != nil && s.Group.Deployment != "" {
+		return s.Group.Deployment
+	}
+	return s.Deployment
+}
+
+// Gated returns true if the step at index i must be triggered
+// manually before it can run. Steps in a manual stage share a
+// single gate placed before the first step of the stage.
+func (s *Stage) Gated(i int) bool {
+	step := s.Steps[i]
+	if step.Trigger == TriggerManual {
+		return true
+	}
+	if step.Group == nil || step.Group.Trigger != TriggerManual {
+		return false
+	}
+	return i == 0 || s.Steps[i-1].Group != step.Group
+}
+
+// Selectors returns the selectors of every pipeline defined in the
+// configuration, sorted by section and pattern.
+func (c *Config) Selectors() []Selector {
+	var selectors []Selector
+	if len(c.Pipelines.Default.Steps) != 0 || c.Pipelines.Default.Import != "" {
+		selectors = append(selectors, Selector{Section: SectionDefault})
+	}
+	for _, section := range []string{
+		SectionBranches,
+		SectionTags,
+		SectionBookmarks,
+		SectionPullRequests,
+		SectionCustom,
+	} {
+		var patterns []string
+		for pattern := range c.sections()[section] {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+		for _, pattern := range patterns {
+			selectors = append(selectors, Selector{Section: section, Pattern: pattern})
 		}
 	}
-	// use default
-	return c.Pipelines.Default
+	return selectors
+}
+
+// Lookup returns the pipeline stage identified by the selector.
+func (c *Config) Lookup(selector Selector) (Stage, bool) {
+	if selector.Section == SectionDefault {
+		return c.Pipelines.Default, true
+	}
+	stage, ok := c.sections()[selector.Section][selector.Pattern]
+	return stage, ok
+}
+
+// sections returns the pattern-based pipeline sections by name.
+func (c *Config) sections() map[string]map[string]Stage {
+	return map[string]map[string]Stage{
+		SectionTags:         c.Pipelines.Tags,
+		SectionBranches:     c.Pipelines.Branches,
+		SectionBookmarks:    c.Pipelines.Bookmarks,
+		SectionPullRequests: c.Pipelines.PullRequests,
+		SectionCustom:       c.Pipelines.Custom,
+	}
 }
 
 // UnmarshalYAML implements custom parsing for the stage section of the yaml
-// to cleanup the structure a bit.
+// to cleanup the structure a bit. Steps declared inside a stage group are
+// flattened into the list of steps and linked to their group.
 func (s *Stage) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	in := []struct {
-		Step *Step
+	ref := struct {
+		Import string
 	}{}
+	if err := unmarshal(&ref); err == nil && ref.Import != "" {
+		s.Import = ref.Import
+		return nil
+	}
+
+	in := []stageItem{}
 	err := unmarshal(&in)
 	if err != nil {
 		return err
 	}
-	for _, step := range in {
-		s.Steps = append(s.Steps, step.Step)
+	for _, item := range in {
+		switch {
+		case item.Parallel != nil:
+			return errors.New("parallel steps are not supported")
+		case item.Stage != nil:
+			steps, err := item.Stage.flatten()
+			if err != nil {
+				return err
+			}
+			s.Steps = append(s.Steps, steps...)
+		case item.Step != nil:
+			if err := validateStep(item.Step); err != nil {
+				return err
+			}
+			s.Steps = append(s.Steps, item.Step)
+		}
+	}
+	if len(s.Steps) != 0 && s.Gated(0) {
+		return errors.New("the first step of a pipeline cannot be manual")
 	}
 	return nil
 }
+
+// UnmarshalYAML implements custom parsing for the artifacts of a step,
+// which are either a list of patterns or an object with the patterns
+// in the paths key. The download key is accepted but has no effect,
+// every step shares the workspace of the previous steps.
+func (a *Artifacts) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var paths []string
+	if err := unmarshal(&paths); err == nil {
+		*a = paths
+		return nil
+	}
+	in := struct {
+		Download *bool
+		Paths    []string
+	}{}
+	if err := unmarshal(&in); err != nil {
+		return err
+	}
+	*a = in.Paths
+	return nil
+}
+
+// stageItem is an entry in the list of steps of a pipeline
+// or stage group.
+type stageItem struct {
+	Step     *Step
+	Stage    *stageGroup
+	Parallel interface{}
+}
+
+// stageGroup is the yaml representation of a stage group.
+type stageGroup struct {
+	Name       string
+	Deployment string
+	Trigger    string
+	Steps      []stageItem
+}
+
+// flatten validates the stage group and returns its steps
+// linked to the group.
+func (g *stageGroup) flatten() ([]*Step, error) {
+	if err := validateTrigger(g.Trigger); err != nil {
+		return nil, fmt.Errorf("stage %q: %s", g.Name, err)
+	}
+	group := &Group{
+		Name:       g.Name,
+		Deployment: g.Deployment,
+		Trigger:    g.Trigger,
+	}
+	var steps []*Step
+	for _, item := range g.Steps {
+		switch {
+		case item.Parallel != nil:
+			return nil, fmt.Errorf("stage %q: parallel steps are not allowed inside a stage", g.Name)
+		case item.Stage != nil:
+			return nil, fmt.Errorf("stage %q: stages cannot be nested", g.Name)
+		case item.Step == nil:
+			continue
+		case item.Step.Deployment != "":
+			return nil, fmt.Errorf("stage %q: steps inside a stage cannot define a deployment", g.Name)
+		case item.Step.Trigger != "":
+			return nil, fmt.Errorf("stage %q: steps inside a stage cannot define a trigger", g.Name)
+		}
+		if err := validateStep(item.Step); err != nil {
+			return nil, fmt.Errorf("stage %q: %s", g.Name, err)
+		}
+		item.Step.Group = group
+		steps = append(steps, item.Step)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("stage %q: a stage must contain at least one step", g.Name)
+	}
+	return steps, nil
+}
+
+// validateStep returns an error if the step uses an unsupported
+// trigger or runtime architecture.
+func validateStep(step *Step) error {
+	if err := validateTrigger(step.Trigger); err != nil {
+		return err
+	}
+	switch step.Runtime.Cloud.Arch {
+	case "", ArchX86, ArchARM:
+		return nil
+	default:
+		return fmt.Errorf("unsupported runtime arch %q", step.Runtime.Cloud.Arch)
+	}
+}
+
+// validateTrigger returns an error if the trigger value
+// is not supported.
+func validateTrigger(trigger string) error {
+	switch trigger {
+	case "", TriggerAutomatic, TriggerManual:
+		return nil
+	default:
+		return fmt.Errorf("unsupported trigger %q", trigger)
+	}
+}
@@ -2,6 +2,7 @@ package bitbucket
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -28,6 +29,64 @@ func TestMatchPipeline(t *testing.T) {
 	}
 }
 
+func TestMatchCandidates(t *testing.T) {
+	config, err := ParseString(matchYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the exact branch name takes precedence over the glob
+	// patterns, which are all reported as candidates.
+	selector, candidates := config.Match("refs/heads/master", "master")
+	if want, got := (Selector{Section: SectionBranches, Pattern: "master"}), selector; want != got {
+		t.Errorf("Wanted selector %s, got %s", want, got)
+	}
+	want := []*Candidate{
+		{Selector: Selector{Section: SectionTags, Pattern: "v*"}},
+		{Selector: Selector{Section: SectionBranches, Pattern: "master"}, Matched: true},
+		{Selector: Selector{Section: SectionBranches, Pattern: "*"}, Matched: true},
+		{Selector: Selector{Section: SectionBranches, Pattern: "feature/*"}},
+	}
+	if !reflect.DeepEqual(candidates, want) {
+		t.Errorf("Wanted candidates %v, got %v", want, candidates)
+	}
+
+	selector, _ = config.Match("refs/heads/develop", "develop")
+	if want, got := (Selector{Section: SectionBranches, Pattern: "*"}), selector; want != got {
+		t.Errorf("Wanted selector %s, got %s", want, got)
+	}
+	selector, _ = config.Match("refs/tags/v1.0.0", "master")
+	if want, got := (Selector{Section: SectionTags, Pattern: "v*"}), selector; want != got {
+		t.Errorf("Wanted tag selector %s, got %s", want, got)
+	}
+	selector, _ = config.Match("refs/heads/feature/a/b", "feature/a/b")
+	if want, got := (Selector{Section: SectionDefault}), selector; want != got {
+		t.Errorf("Wanted default selector, got %s", got)
+	}
+}
+
+var matchYaml = `
+pipelines:
+  default:
+    - step:
+        script: [ make ]
+  branches:
+    "*":
+      - step:
+          script: [ make ]
+    master:
+      - step:
+          script: [ make ]
+    feature/*:
+      - step:
+          script: [ make ]
+  tags:
+    v*:
+      - step:
+          script: [ make ]
+`
+
 var pipelineYaml = `
 image: node:latest
 
@@ -50,3 +109,257 @@ pipelines:
           script:
             - echo "Clone all the things!"
 `
+
+func TestStageGroups(t *testing.T) {
+	config, err := ParseString(stageYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	steps := config.Pipelines.Default.Steps
+	if want, got := 4, len(steps); want != got {
+		t.Errorf("Wanted %d steps, got %d", want, got)
+		t.FailNow()
+	}
+	if steps[0].Group != nil {
+		t.Errorf("Expect step outside a stage to have no group")
+	}
+	if steps[1].Group == nil || steps[1].Group != steps[2].Group {
+		t.Errorf("Expect steps in a stage to share a group")
+		t.FailNow()
+	}
+	if want, got := "Deploy to staging", steps[1].Group.Name; want != got {
+		t.Errorf("Wanted group name %q, got %q", want, got)
+	}
+	if want, got := "staging", steps[2].Target(); want != got {
+		t.Errorf("Wanted step to inherit deployment %q, got %q", want, got)
+	}
+	if want, got := "production", steps[3].Target(); want != got {
+		t.Errorf("Wanted step deployment %q, got %q", want, got)
+	}
+
+	pipeline := config.Pipelines.Default
+	for i, want := range []bool{false, true, false, true} {
+		if got := pipeline.Gated(i); got != want {
+			t.Errorf("Wanted step %d gated %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestStageGroupsInvalid(t *testing.T) {
+	tests := []struct {
+		yaml string
+		want string
+	}{
+		{
+			yaml: stageParallelYaml,
+			want: `stage "deploy": parallel steps are not allowed inside a stage`,
+		},
+		{
+			yaml: stageDeploymentYaml,
+			want: `stage "deploy": steps inside a stage cannot define a deployment`,
+		},
+		{
+			yaml: stageEmptyYaml,
+			want: `stage "deploy": a stage must contain at least one step`,
+		},
+		{
+			yaml: stageManualFirstYaml,
+			want: "the first step of a pipeline cannot be manual",
+		},
+	}
+	for _, test := range tests {
+		_, err := ParseString(test.yaml)
+		if err == nil {
+			t.Errorf("Expect error %q", test.want)
+			continue
+		}
+		if got := err.Error(); got != test.want {
+			t.Errorf("Wanted error %q, got %q", test.want, got)
+		}
+	}
+}
+
+var stageYaml = `
+image: node:latest
+
+pipelines:
+  default:
+    - step:
+        script:
+          - npm test
+    - stage:
+        name: Deploy to staging
+        deployment: staging
+        trigger: manual
+        steps:
+          - step:
+              script:
+                - npm run build
+          - step:
+              script:
+                - npm run deploy
+    - step:
+        deployment: production
+        trigger: manual
+        script:
+          - npm run deploy
+`
+
+var stageParallelYaml = `
+pipelines:
+  default:
+    - step:
+        script:
+          - npm test
+    - stage:
+        name: deploy
+        deployment: staging
+        steps:
+          - parallel:
+              - step:
+                  script:
+                    - npm run deploy
+`
+
+var stageDeploymentYaml = `
+pipelines:
+  default:
+    - stage:
+        name: deploy
+        steps:
+          - step:
+              deployment: staging
+              script:
+                - npm run deploy
+`
+
+var stageEmptyYaml = `
+pipelines:
+  default:
+    - stage:
+        name: deploy
+        deployment: staging
+`
+
+var stageManualFirstYaml = `
+pipelines:
+  default:
+    - stage:
+        name: deploy
+        trigger: manual
+        steps:
+          - step:
+              script:
+                - npm run deploy
+`
+
+func TestSelectors(t *testing.T) {
+	config, err := ParseString(sectionsYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	want := []Selector{
+		{Section: SectionDefault},
+		{Section: SectionBranches, Pattern: "feature/*"},
+		{Section: SectionBranches, Pattern: "master"},
+		{Section: SectionTags, Pattern: "v*"},
+		{Section: SectionBookmarks, Pattern: "stable"},
+		{Section: SectionPullRequests, Pattern: "**"},
+		{Section: SectionCustom, Pattern: "deploy"},
+	}
+	got := config.Selectors()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wanted selectors %v, got %v", want, got)
+	}
+	for _, selector := range got {
+		if _, ok := config.Lookup(selector); !ok {
+			t.Errorf("Expect lookup of %s", selector)
+		}
+		parsed, err := ParseSelector(selector.String())
+		if err != nil {
+			t.Error(err)
+		} else if parsed != selector {
+			t.Errorf("Wanted parsed selector %v, got %v", selector, parsed)
+		}
+	}
+}
+
+var sectionsYaml = `
+pipelines:
+  default:
+    - step:
+        script: [ make ]
+  branches:
+    master:
+      - step:
+          script: [ make ]
+    feature/*:
+      - step:
+          script: [ make ]
+  tags:
+    v*:
+      - step:
+          script: [ make ]
+  bookmarks:
+    stable:
+      - step:
+          script: [ make ]
+  pull-requests:
+    "**":
+      - step:
+          script: [ make ]
+  custom:
+    deploy:
+      - step:
+          script: [ make ]
+`
+
+func TestStepArtifacts(t *testing.T) {
+	config, err := ParseString(artifactsYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	steps := config.Pipelines.Default.Steps
+	for i, want := range []string{"dist/**", "dist/**,reports/*.xml", ""} {
+		if got := strings.Join(steps[i].Artifacts, ","); got != want {
+			t.Errorf("Wanted step %d artifacts %q, got %q", i, want, got)
+		}
+	}
+
+	if _, err := ParseString(artifactsInvalidYaml); err == nil {
+		t.Errorf("Expect error for artifacts that are neither a list nor an object")
+	}
+}
+
+var artifactsYaml = `
+pipelines:
+  default:
+    - step:
+        script: [ make ]
+        artifacts:
+          - dist/**
+    - step:
+        script: [ make test ]
+        artifacts:
+          download: false
+          paths:
+            - dist/**
+            - reports/*.xml
+    - step:
+        script: [ make deploy ]
+        artifacts:
+          download: false
+`
+
+var artifactsInvalidYaml = `
+pipelines:
+  default:
+    - step:
+        script: [ make ]
+        artifacts: dist/**
+`
@@ -0,0 +1,200 @@
+// Package convert converts the bitbucket-pipelines.yml configuration
+// to the configuration formats of other continuous integration systems.
+package convert
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/cncd/bitbucket-frontend"
+)
+
+// defaultImage is the image Bitbucket uses to run steps when the
+// configuration does not specify an image.
+const defaultImage = "atlassian/default-image:latest"
+
+type (
+	// Result contains the converted configuration files and the
+	// warnings raised during conversion.
+	Result struct {
+		Files    []*File
+		Warnings []*Warning
+	}
+
+	// File is a converted configuration file.
+	File struct {
+		Name string
+		Data []byte
+	}
+
+	// Warning describes a construct of the configuration that
+	// was approximated or has no equivalent in the target format.
+	Warning struct {
+		Pipeline string
+		Message  string
+	}
+
+	// Converter converts the configuration to a target format.
+	Converter func(*bitbucket.Config) (*Result, error)
+)
+
+// String returns the warning prefixed with the pipeline selector.
+func (w *Warning) String() string {
+	if w.Pipeline == "" {
+		return w.Message
+	}
+	return w.Pipeline + ": " + w.Message
+}
+
+// converters is the registry of converters by target name.
+var converters = map[string]Converter{
+	"drone":          Drone,
+	"github-actions": GitHubActions,
+	"gitlab":         GitLab,
+}
+
+// Targets returns the sorted names of the supported target formats.
+func Targets() []string {
+	var targets []string
+	for name := range converters {
+		targets = append(targets, name)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Convert converts the configuration to the named target format.
+func Convert(conf *bitbucket.Config, target string) (*Result, error) {
+	converter, ok := converters[target]
+	if !ok {
+		return nil, fmt.Errorf("unsupported conversion target %q", target)
+	}
+	return converter(conf)
+}
+
+// warnf adds a formatted warning for the pipeline to the result.
+func (r *Result) warnf(selector bitbucket.Selector, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, &Warning{
+		Pipeline: selector.String(),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// stepImage returns the image of the step, falling back to the
+// global image and then the Bitbucket default image.
+func stepImage(conf *bitbucket.Config, step *bitbucket.Step) string {
+	switch {
+	case step.Image != "":
+		return step.Image
+	case conf.Image != "":
+		return conf.Image
+	default:
+		return defaultImage
+	}
+}
+
+// stepNames returns a unique name for each step in the stage. Steps
+// without a name are named after their position in the pipeline.
+func stepNames(stage bitbucket.Stage) []string {
+	var names []string
+	seen := map[string]bool{}
+	for i, step := range stage.Steps {
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step_%d", i)
+		}
+		if seen[name] {
+			name = fmt.Sprintf("%s_%d", name, i)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// stepServices returns the union of the services referenced by the
+// steps of the stage, in order of first use.
+func stepServices(stage bitbucket.Stage) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, step := range stage.Steps {
+		for _, name := range step.Services {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// cachePath returns the directory persisted by the named cache, which
+// is either defined in the configuration or predefined by Bitbucket.
+// It returns an error if the cache has no directory to convert.
+func cachePath(conf *bitbucket.Config, name string) (string, error) {
+	if path, ok := conf.Definitions.Caches[name]; ok {
+		return path, nil
+	}
+	path, ok := bitbucket.PredefinedCaches[name]
+	switch {
+	case !ok:
+		return "", fmt.Errorf("cache %s is not defined", name)
+	case path == "":
+		return "", fmt.Errorf("cache %s is not converted, it has no directory", name)
+	}
+	return path, nil
+}
+
+// slug returns the lowercase name with every run of characters other
+// than letters and digits replaced by a dash.
+func slug(name string) string {
+	var parts []string
+	for _, part := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "-")
+}
+
+// globRegexp returns a regular expression, without delimiters, that
+// matches the same names as the Bitbucket glob pattern.
+func globRegexp(pattern string) string {
+	var buf strings.Builder
+	var depth int
+	buf.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				buf.WriteString(".*")
+				i++
+			} else {
+				buf.WriteString(`[^\/]*`)
+			}
+		case '?':
+			buf.WriteString(`[^\/]`)
+		case '{':
+			depth++
+			buf.WriteString("(")
+		case '}':
+			depth--
+			buf.WriteString(")")
+		case ',':
+			if depth > 0 {
+				buf.WriteString("|")
+			} else {
+				buf.WriteString(",")
+			}
+		case '/':
+			buf.WriteString(`\/`)
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	buf.WriteString("$")
+	return buf.String()
+}
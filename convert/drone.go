@@ -0,0 +1,196 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/cncd/bitbucket-frontend"
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// droneDocument is a pipeline document of the .drone.yml
+	// configuration file.
+	droneDocument struct {
+		Kind     string          `yaml:"kind"`
+		Type     string          `yaml:"type"`
+		Name     string          `yaml:"name"`
+		Clone    *droneClone     `yaml:"clone,omitempty"`
+		Steps    []*droneStep    `yaml:"steps"`
+		Services []*droneService `yaml:"services,omitempty"`
+		Trigger  *droneTrigger   `yaml:"trigger,omitempty"`
+	}
+
+	droneClone struct {
+		Depth int `yaml:"depth,omitempty"`
+	}
+
+	droneStep struct {
+		Name     string   `yaml:"name"`
+		Image    string   `yaml:"image"`
+		Commands []string `yaml:"commands,omitempty"`
+	}
+
+	droneService struct {
+		Name        string            `yaml:"name"`
+		Image       string            `yaml:"image"`
+		Privileged  bool              `yaml:"privileged,omitempty"`
+		Environment map[string]string `yaml:"environment,omitempty"`
+	}
+
+	droneTrigger struct {
+		Event  []string        `yaml:"event,omitempty"`
+		Branch *droneCondition `yaml:"branch,omitempty"`
+		Ref    *droneCondition `yaml:"ref,omitempty"`
+	}
+
+	droneCondition struct {
+		Include []string `yaml:"include,omitempty"`
+		Exclude []string `yaml:"exclude,omitempty"`
+	}
+)
+
+// Drone converts the configuration to a .drone.yml configuration file
+// with one pipeline document for each pipeline in the configuration.
+func Drone(conf *bitbucket.Config) (*Result, error) {
+	result := new(Result)
+
+	var buf bytes.Buffer
+	for _, selector := range conf.Selectors() {
+		stage, _ := conf.Lookup(selector)
+
+		trigger, ok := droneTriggerFor(conf, selector, result)
+		if !ok {
+			continue
+		}
+
+		doc := &droneDocument{
+			Kind:    "pipeline",
+			Type:    "docker",
+			Name:    selector.String(),
+			Trigger: trigger,
+		}
+		if conf.Clone.Depth != 0 {
+			doc.Clone = &droneClone{Depth: conf.Clone.Depth}
+		}
+
+		names := stepNames(stage)
+		for i, step := range stage.Steps {
+			doc.Steps = append(doc.Steps, &droneStep{
+				Name:     names[i],
+				Image:    stepImage(conf, step),
+				Commands: step.Script,
+			})
+			if target := step.Target(); target != "" {
+				result.warnf(selector, "step %s: deployment environment %s is not converted, configure its variables as secrets", names[i], target)
+			}
+			if stage.Gated(i) {
+				result.warnf(selector, "step %s: manual trigger is not converted, the step runs automatically", names[i])
+			}
+			if len(step.Caches) != 0 {
+				result.warnf(selector, "step %s: caches %s are not converted, use a cache plugin", names[i], strings.Join(step.Caches, ", "))
+			}
+		}
+
+		services := stepServices(stage)
+		for _, step := range stage.Steps {
+			if len(step.Services) != len(services) {
+				result.warnf(selector, "services run for every step in the pipeline, not only the steps that reference them")
+				break
+			}
+		}
+		for _, name := range services {
+			service, ok := droneServiceFor(conf, name)
+			if !ok {
+				result.warnf(selector, "service %s is not defined", name)
+				continue
+			}
+			if def := conf.Definitions.Services[name]; def.Memory != 0 {
+				result.warnf(selector, "service %s: memory limit is not converted", name)
+			}
+			if service.Privileged {
+				result.warnf(selector, "service %s: steps must set DOCKER_HOST=tcp://docker:2375 to use the docker daemon", name)
+			}
+			doc.Services = append(doc.Services, service)
+		}
+
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("---\n")
+		buf.Write(out)
+	}
+
+	result.Files = append(result.Files, &File{
+		Name: ".drone.yml",
+		Data: buf.Bytes(),
+	})
+	return result, nil
+}
+
+// droneTriggerFor returns the trigger that selects the pipeline. It
+// returns false if the pipeline cannot be selected in Drone.
+func droneTriggerFor(conf *bitbucket.Config, selector bitbucket.Selector, result *Result) (*droneTrigger, bool) {
+	switch selector.Section {
+	case bitbucket.SectionDefault:
+		// the default pipeline runs for every branch that does
+		// not match a branch specific pipeline.
+		trigger := &droneTrigger{Event: []string{"push"}}
+		for _, other := range conf.Selectors() {
+			if other.Section == bitbucket.SectionBranches {
+				if trigger.Branch == nil {
+					trigger.Branch = new(droneCondition)
+				}
+				trigger.Branch.Exclude = append(trigger.Branch.Exclude, other.Pattern)
+			}
+		}
+		return trigger, true
+	case bitbucket.SectionBranches:
+		return &droneTrigger{
+			Event:  []string{"push"},
+			Branch: &droneCondition{Include: []string{selector.Pattern}},
+		}, true
+	case bitbucket.SectionTags:
+		return &droneTrigger{
+			Event: []string{"tag"},
+			Ref:   &droneCondition{Include: []string{"refs/tags/" + selector.Pattern}},
+		}, true
+	case bitbucket.SectionPullRequests:
+		trigger := &droneTrigger{Event: []string{"pull_request"}}
+		if selector.Pattern != "**" {
+			result.warnf(selector, "pull request pattern is matched against the target branch instead of the source branch")
+			trigger.Branch = &droneCondition{Include: []string{selector.Pattern}}
+		}
+		return trigger, true
+	case bitbucket.SectionCustom:
+		result.warnf(selector, "custom pipeline runs for every custom build, not only when selected by name")
+		return &droneTrigger{Event: []string{"custom"}}, true
+	default:
+		result.warnf(selector, "%s pipelines are not supported, the pipeline is not converted", selector.Section)
+		return nil, false
+	}
+}
+
+// droneServiceFor returns the service definition for the named service.
+// The docker service is predefined by Bitbucket and converted to a
+// docker in docker service.
+func droneServiceFor(conf *bitbucket.Config, name string) (*droneService, bool) {
+	def, ok := conf.Definitions.Services[name]
+	switch {
+	case ok:
+		return &droneService{
+			Name:        name,
+			Image:       def.Image,
+			Environment: def.Variables,
+		}, true
+	case name == "docker":
+		return &droneService{
+			Name:       name,
+			Image:      "docker:dind",
+			Privileged: true,
+		}, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,102 @@
+package convert
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cncd/bitbucket-frontend"
+	"gopkg.in/yaml.v2"
+)
+
+func TestDrone(t *testing.T) {
+	conf, err := bitbucket.ParseFile("testdata/full.yml")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	result, err := Drone(conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := 1, len(result.Files); want != got {
+		t.Errorf("Wanted %d file, got %d", want, got)
+		t.FailNow()
+	}
+	if want, got := ".drone.yml", result.Files[0].Name; want != got {
+		t.Errorf("Wanted file name %s, got %s", want, got)
+	}
+
+	docs := parseDocuments(t, result.Files[0].Data)
+	if want, got := 5, len(docs); want != got {
+		t.Errorf("Wanted %d pipeline documents, got %d", want, got)
+		t.FailNow()
+	}
+
+	def := docs[0]
+	if want, got := "default", def.Name; want != got {
+		t.Errorf("Wanted pipeline %s, got %s", want, got)
+	}
+	if want, got := 25, def.Clone.Depth; want != got {
+		t.Errorf("Wanted clone depth %d, got %d", want, got)
+	}
+	if def.Trigger.Branch == nil || len(def.Trigger.Branch.Exclude) != 1 || def.Trigger.Branch.Exclude[0] != "master" {
+		t.Errorf("Expect default pipeline to exclude branch pipelines")
+	}
+	if want, got := "node:7.4.0", def.Steps[0].Image; want != got {
+		t.Errorf("Wanted step image %s, got %s", want, got)
+	}
+	if want, got := "golang:1.8", def.Steps[1].Image; want != got {
+		t.Errorf("Wanted step image %s, got %s", want, got)
+	}
+	if len(def.Services) != 1 || def.Services[0].Image != "postgres:9.6" {
+		t.Errorf("Expect postgres service in default pipeline")
+	}
+
+	tags := docs[2]
+	if tags.Trigger.Ref == nil || tags.Trigger.Ref.Include[0] != "refs/tags/release-*" {
+		t.Errorf("Expect tag pipeline to trigger on the tag ref")
+	}
+
+	warnings := map[string]bool{}
+	for _, warning := range result.Warnings {
+		warnings[warning.String()] = true
+	}
+	for _, want := range []string{
+		"default: step test: caches node are not converted, use a cache plugin",
+		"default: services run for every step in the pipeline, not only the steps that reference them",
+		"default: service postgres: memory limit is not converted",
+		"branches/master: step deploy: deployment environment production is not converted, configure its variables as secrets",
+		"branches/master: step deploy: manual trigger is not converted, the step runs automatically",
+		"bookmarks/stable: bookmarks pipelines are not supported, the pipeline is not converted",
+		"custom/nightly: custom pipeline runs for every custom build, not only when selected by name",
+	} {
+		if !warnings[want] {
+			t.Errorf("Expect warning %q", want)
+		}
+	}
+}
+
+func TestConvertUnsupported(t *testing.T) {
+	_, err := Convert(new(bitbucket.Config), "jenkins")
+	if err == nil {
+		t.Errorf("Expect error converting to an unsupported target")
+	}
+}
+
+// parseDocuments parses the pipeline documents of a .drone.yml file.
+func parseDocuments(t *testing.T, data []byte) []*droneDocument {
+	var docs []*droneDocument
+	for _, part := range bytes.Split(data, []byte("---\n")) {
+		if len(part) == 0 {
+			continue
+		}
+		doc := new(droneDocument)
+		if err := yaml.Unmarshal(part, doc); err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
@@ -0,0 +1,260 @@
+package convert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cncd/bitbucket-frontend"
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// githubWorkflow is a GitHub Actions workflow file.
+	githubWorkflow struct {
+		Name string        `yaml:"name"`
+		On   *githubOn     `yaml:"on"`
+		Jobs yaml.MapSlice `yaml:"jobs"`
+	}
+
+	githubOn struct {
+		Push             *githubFilter `yaml:"push,omitempty"`
+		PullRequest      *githubFilter `yaml:"pull_request,omitempty"`
+		WorkflowDispatch *struct{}     `yaml:"workflow_dispatch,omitempty"`
+	}
+
+	githubFilter struct {
+		Branches       []string `yaml:"branches,omitempty"`
+		BranchesIgnore []string `yaml:"branches-ignore,omitempty"`
+		Tags           []string `yaml:"tags,omitempty"`
+	}
+
+	githubJob struct {
+		Name        string                    `yaml:"name,omitempty"`
+		RunsOn      string                    `yaml:"runs-on"`
+		Needs       []string                  `yaml:"needs,omitempty"`
+		Environment string                    `yaml:"environment,omitempty"`
+		Container   string                    `yaml:"container,omitempty"`
+		Services    map[string]*githubService `yaml:"services,omitempty"`
+		Steps       []*githubStep             `yaml:"steps"`
+	}
+
+	githubService struct {
+		Image string            `yaml:"image"`
+		Env   map[string]string `yaml:"env,omitempty"`
+	}
+
+	githubStep struct {
+		Name string            `yaml:"name,omitempty"`
+		Uses string            `yaml:"uses,omitempty"`
+		With map[string]string `yaml:"with,omitempty"`
+		Run  string            `yaml:"run,omitempty"`
+	}
+)
+
+// GitHubActions converts the configuration to GitHub Actions workflow
+// files, one for each pipeline in the configuration. The steps of a
+// pipeline are converted to jobs that run in sequence, and artifacts
+// are passed between the jobs with workflow artifacts.
+func GitHubActions(conf *bitbucket.Config) (*Result, error) {
+	result := new(Result)
+
+	seen := map[string]bool{}
+	for _, selector := range conf.Selectors() {
+		stage, _ := conf.Lookup(selector)
+
+		on, ok := githubOnFor(conf, selector, result)
+		if !ok {
+			continue
+		}
+		workflow := &githubWorkflow{
+			Name: selector.String(),
+			On:   on,
+		}
+
+		names := stepNames(stage)
+		ids := githubJobIDs(names)
+		var prev string
+
+		// artifacts are uploaded by the job of the step that
+		// produces them and downloaded by every following job,
+		// since jobs do not share a workspace.
+		var artifacts []string
+		for i, step := range stage.Steps {
+			id := ids[i]
+			job := &githubJob{
+				Name:        names[i],
+				RunsOn:      "ubuntu-latest",
+				Container:   stepImage(conf, step),
+				Environment: step.Target(),
+			}
+			if prev != "" {
+				job.Needs = []string{prev}
+			}
+			prev = id
+
+			checkout := &githubStep{Uses: "actions/checkout@v4"}
+			if conf.Clone.Depth != 0 {
+				checkout.With = map[string]string{
+					"fetch-depth": strconv.Itoa(conf.Clone.Depth),
+				}
+			}
+			job.Steps = append(job.Steps, checkout)
+
+			for _, name := range artifacts {
+				job.Steps = append(job.Steps, &githubStep{
+					Name: "download " + name,
+					Uses: "actions/download-artifact@v4",
+					With: map[string]string{"name": name},
+				})
+			}
+
+			for _, name := range step.Caches {
+				path, err := cachePath(conf, name)
+				if err != nil {
+					result.warnf(selector, "step %s: %s", names[i], err)
+					continue
+				}
+				job.Steps = append(job.Steps, &githubStep{
+					Name: "cache " + name,
+					Uses: "actions/cache@v4",
+					With: map[string]string{
+						"path":         path,
+						"key":          fmt.Sprintf("%s-${{ github.sha }}", name),
+						"restore-keys": name + "-",
+					},
+				})
+				result.warnf(selector, "step %s: cache %s is approximated by actions/cache keyed by commit", names[i], name)
+			}
+
+			for _, name := range step.Services {
+				def, ok := conf.Definitions.Services[name]
+				if !ok {
+					if name == "docker" {
+						result.warnf(selector, "step %s: docker service is not converted, the job container must mount the docker socket of the runner", names[i])
+					} else {
+						result.warnf(selector, "step %s: service %s is not defined", names[i], name)
+					}
+					continue
+				}
+				if job.Services == nil {
+					job.Services = map[string]*githubService{}
+				}
+				job.Services[name] = &githubService{
+					Image: def.Image,
+					Env:   def.Variables,
+				}
+				if def.Memory != 0 {
+					result.warnf(selector, "step %s: service %s: memory limit is not converted", names[i], name)
+				}
+			}
+
+			job.Steps = append(job.Steps, &githubStep{
+				Run: strings.Join(step.Script, "\n"),
+			})
+
+			if len(step.Artifacts) != 0 {
+				job.Steps = append(job.Steps, &githubStep{
+					Name: "upload " + id,
+					Uses: "actions/upload-artifact@v4",
+					With: map[string]string{
+						"name": id,
+						"path": strings.Join(step.Artifacts, "\n"),
+					},
+				})
+				artifacts = append(artifacts, id)
+				result.warnf(selector, "step %s: artifacts are approximated by actions/upload-artifact and actions/download-artifact", names[i])
+			}
+
+			if stage.Gated(i) {
+				if job.Environment != "" {
+					result.warnf(selector, "step %s: manual trigger is approximated by the protection rules of environment %s", names[i], job.Environment)
+				} else {
+					result.warnf(selector, "step %s: manual trigger is not converted, the job runs automatically", names[i])
+				}
+			}
+
+			workflow.Jobs = append(workflow.Jobs, yaml.MapItem{Key: id, Value: job})
+		}
+
+		out, err := yaml.Marshal(workflow)
+		if err != nil {
+			return nil, err
+		}
+
+		// workflow files are named after the pipeline selector.
+		name := slug(selector.String())
+		if name == "" || seen[name] {
+			name = fmt.Sprintf("%s-%d", name, len(seen))
+		}
+		seen[name] = true
+
+		result.Files = append(result.Files, &File{
+			Name: ".github/workflows/" + name + ".yml",
+			Data: out,
+		})
+	}
+	return result, nil
+}
+
+// githubJobIDs returns a unique job id for each step name. Job ids
+// must start with a letter and contain only letters, digits, dashes
+// and underscores.
+func githubJobIDs(names []string) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for i, name := range names {
+		id := slug(name)
+		if id == "" || !unicode.IsLetter(rune(id[0])) {
+			id = "job-" + id
+		}
+		if seen[id] {
+			id = fmt.Sprintf("%s-%d", id, i)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// githubOnFor returns the events that trigger the workflow. It returns
+// false if the pipeline cannot be triggered in GitHub Actions.
+func githubOnFor(conf *bitbucket.Config, selector bitbucket.Selector, result *Result) (*githubOn, bool) {
+	switch selector.Section {
+	case bitbucket.SectionDefault:
+		// the default pipeline runs for every branch that does
+		// not match a branch specific pipeline. A branch filter
+		// is always set so the workflow does not run for tags.
+		filter := new(githubFilter)
+		for _, other := range conf.Selectors() {
+			if other.Section == bitbucket.SectionBranches {
+				filter.BranchesIgnore = append(filter.BranchesIgnore, other.Pattern)
+			}
+		}
+		if len(filter.BranchesIgnore) == 0 {
+			filter.Branches = []string{"**"}
+		}
+		return &githubOn{Push: filter}, true
+	case bitbucket.SectionBranches:
+		return &githubOn{
+			Push: &githubFilter{Branches: []string{selector.Pattern}},
+		}, true
+	case bitbucket.SectionTags:
+		return &githubOn{
+			Push: &githubFilter{Tags: []string{selector.Pattern}},
+		}, true
+	case bitbucket.SectionPullRequests:
+		filter := new(githubFilter)
+		if selector.Pattern != "**" {
+			result.warnf(selector, "pull request pattern is matched against the base branch instead of the source branch")
+			filter.Branches = []string{selector.Pattern}
+		}
+		return &githubOn{PullRequest: filter}, true
+	case bitbucket.SectionCustom:
+		return &githubOn{WorkflowDispatch: &struct{}{}}, true
+	default:
+		result.warnf(selector, "%s pipelines are not supported, the pipeline is not converted", selector.Section)
+		return nil, false
+	}
+}
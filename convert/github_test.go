@@ -0,0 +1,176 @@
+package convert
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cncd/bitbucket-frontend"
+	"gopkg.in/yaml.v2"
+)
+
+func TestGitHubActions(t *testing.T) {
+	conf, err := bitbucket.ParseFile("testdata/full.yml")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	result, err := GitHubActions(conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var names []string
+	for _, file := range result.Files {
+		names = append(names, file.Name)
+	}
+	want := []string{
+		".github/workflows/default.yml",
+		".github/workflows/branches-master.yml",
+		".github/workflows/tags-release.yml",
+		".github/workflows/pull-requests.yml",
+		".github/workflows/custom-nightly.yml",
+	}
+	if len(names) != len(want) {
+		t.Errorf("Wanted files %v, got %v", want, names)
+		t.FailNow()
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("Wanted file %s, got %s", want[i], names[i])
+		}
+	}
+
+	workflow := struct {
+		On   map[string]githubFilter
+		Jobs map[string]githubJob
+	}{}
+	if err := yaml.Unmarshal(result.Files[1].Data, &workflow); err != nil {
+		t.Error(err)
+		return
+	}
+	if branches := workflow.On["push"].Branches; len(branches) != 1 || branches[0] != "master" {
+		t.Errorf("Expect push filter on the master branch, got %v", branches)
+	}
+	deploy, ok := workflow.Jobs["deploy"]
+	if !ok {
+		t.Errorf("Expect deploy job")
+		t.FailNow()
+	}
+	if len(deploy.Needs) != 1 || deploy.Needs[0] != "test" {
+		t.Errorf("Expect deploy job to need the test job, got %v", deploy.Needs)
+	}
+	if want, got := "production", deploy.Environment; want != got {
+		t.Errorf("Wanted environment %s, got %s", want, got)
+	}
+	if want, got := "node:7.4.0", deploy.Container; want != got {
+		t.Errorf("Wanted container %s, got %s", want, got)
+	}
+
+	if err := yaml.Unmarshal(result.Files[0].Data, &workflow); err != nil {
+		t.Error(err)
+		return
+	}
+	test := workflow.Jobs["test"]
+	if service := test.Services["postgres"]; service == nil || service.Image != "postgres:9.6" {
+		t.Errorf("Expect postgres service on the test job")
+	}
+	if want, got := "npm install\nnpm test", test.Steps[len(test.Steps)-1].Run; want != got {
+		t.Errorf("Wanted script %q, got %q", want, got)
+	}
+
+	warnings := map[string]bool{}
+	for _, warning := range result.Warnings {
+		warnings[warning.String()] = true
+	}
+	for _, want := range []string{
+		"default: step test: cache node is approximated by actions/cache keyed by commit",
+		"default: step test: service postgres: memory limit is not converted",
+		"branches/master: step deploy: manual trigger is approximated by the protection rules of environment production",
+		"branches/master: step deploy: docker service is not converted, the job container must mount the docker socket of the runner",
+		"bookmarks/stable: bookmarks pipelines are not supported, the pipeline is not converted",
+	} {
+		if !warnings[want] {
+			t.Errorf("Expect warning %q", want)
+		}
+	}
+}
+
+func TestGitHubJobIDs(t *testing.T) {
+	got := githubJobIDs([]string{"Build", "build", "1st step", "step_3"})
+	want := []string{"build", "build-1", "job-1st-step", "step-3"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Wanted job id %s, got %s", want[i], got[i])
+		}
+	}
+}
+
+func TestGitHubActionsGolden(t *testing.T) {
+	tests := map[string]string{
+		"artifacts": "testdata/artifacts.yml",
+	}
+	for name, file := range tests {
+		conf, err := bitbucket.ParseFile(file)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		result, err := GitHubActions(conf)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if want, got := ".github/workflows/default.yml", result.Files[0].Name; want != got {
+			t.Errorf("%s: wanted file name %s, got %s", name, want, got)
+		}
+
+		golden := filepath.Join("testdata", "github", name+".golden")
+		if *update {
+			if err := ioutil.WriteFile(golden, result.Files[0].Data, 0644); err != nil {
+				t.Error(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if got := result.Files[0].Data; string(got) != string(want) {
+			t.Errorf("%s: output does not match %s, got\n%s", name, golden, got)
+		}
+	}
+}
+
+func TestGitHubActionsArtifactWarnings(t *testing.T) {
+	conf, err := bitbucket.ParseFile("testdata/artifacts.yml")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result, err := GitHubActions(conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var warnings []string
+	for _, warning := range result.Warnings {
+		warnings = append(warnings, warning.String())
+	}
+	want := []string{
+		"default: step build: artifacts are approximated by actions/upload-artifact and actions/download-artifact",
+		"default: step package: artifacts are approximated by actions/upload-artifact and actions/download-artifact",
+	}
+	if len(warnings) != len(want) {
+		t.Errorf("Wanted warnings %q, got %q", want, warnings)
+		return
+	}
+	for i := range want {
+		if want[i] != warnings[i] {
+			t.Errorf("Wanted warning %q, got %q", want[i], warnings[i])
+		}
+	}
+}
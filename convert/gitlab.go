@@ -0,0 +1,206 @@
+package convert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cncd/bitbucket-frontend"
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	gitlabJob struct {
+		Stage        string           `yaml:"stage"`
+		Image        string           `yaml:"image"`
+		Services     []*gitlabService `yaml:"services,omitempty"`
+		Variables    yaml.MapSlice    `yaml:"variables,omitempty"`
+		Script       []string         `yaml:"script"`
+		Cache        []*gitlabCache   `yaml:"cache,omitempty"`
+		Artifacts    *gitlabArtifacts `yaml:"artifacts,omitempty"`
+		Environment  string           `yaml:"environment,omitempty"`
+		AllowFailure *bool            `yaml:"allow_failure,omitempty"`
+		Rules        []*gitlabRule    `yaml:"rules"`
+	}
+
+	gitlabService struct {
+		Name      string            `yaml:"name"`
+		Alias     string            `yaml:"alias"`
+		Variables map[string]string `yaml:"variables,omitempty"`
+	}
+
+	gitlabCache struct {
+		Key   string   `yaml:"key"`
+		Paths []string `yaml:"paths"`
+	}
+
+	gitlabArtifacts struct {
+		Paths []string `yaml:"paths"`
+	}
+
+	gitlabRule struct {
+		If   string `yaml:"if"`
+		When string `yaml:"when,omitempty"`
+	}
+)
+
+// GitLab converts the configuration to a .gitlab-ci.yml configuration
+// file. Every step is converted to a job in its own stage, ordered as
+// in the pipeline, with rules that select the pipeline.
+func GitLab(conf *bitbucket.Config) (*Result, error) {
+	result := new(Result)
+
+	var stages []string
+	var jobs yaml.MapSlice
+	for _, selector := range conf.Selectors() {
+		stage, _ := conf.Lookup(selector)
+
+		rules, ok := gitlabRulesFor(conf, selector, result)
+		if !ok {
+			continue
+		}
+
+		names := stepNames(stage)
+		for i, step := range stage.Steps {
+			name := selector.String() + " " + names[i]
+			stages = append(stages, name)
+
+			job := &gitlabJob{
+				Stage:       name,
+				Image:       stepImage(conf, step),
+				Script:      step.Script,
+				Environment: step.Target(),
+			}
+
+			when := ""
+			if stage.Gated(i) {
+				// a blocking manual job pauses the pipeline
+				// until it is triggered, as in Bitbucket.
+				when = "manual"
+				allow := false
+				job.AllowFailure = &allow
+			}
+			for _, rule := range rules {
+				if rule.When == "" {
+					rule = &gitlabRule{If: rule.If, When: when}
+				}
+				job.Rules = append(job.Rules, rule)
+			}
+
+			for _, cache := range step.Caches {
+				path, err := cachePath(conf, cache)
+				if err != nil {
+					result.warnf(selector, "step %s: %s", names[i], err)
+					continue
+				}
+				if strings.HasPrefix(path, "~") || strings.HasPrefix(path, "/") {
+					result.warnf(selector, "step %s: cache %s is outside the project directory, which GitLab cannot cache", names[i], cache)
+				}
+				job.Cache = append(job.Cache, &gitlabCache{
+					Key:   cache,
+					Paths: []string{path},
+				})
+			}
+
+			if len(step.Artifacts) != 0 {
+				job.Artifacts = &gitlabArtifacts{Paths: step.Artifacts}
+			}
+
+			for _, service := range step.Services {
+				def, ok := conf.Definitions.Services[service]
+				switch {
+				case ok:
+					job.Services = append(job.Services, &gitlabService{
+						Name:      def.Image,
+						Alias:     service,
+						Variables: def.Variables,
+					})
+					if def.Memory != 0 {
+						result.warnf(selector, "step %s: service %s: memory limit is not converted", names[i], service)
+					}
+				case service == "docker":
+					job.Services = append(job.Services, &gitlabService{
+						Name:  "docker:dind",
+						Alias: service,
+					})
+					job.Variables = append(job.Variables, yaml.MapItem{
+						Key:   "DOCKER_HOST",
+						Value: "tcp://docker:2375",
+					})
+					result.warnf(selector, "step %s: docker service requires a runner with privileged mode enabled", names[i])
+				default:
+					result.warnf(selector, "step %s: service %s is not defined", names[i], service)
+				}
+			}
+
+			jobs = append(jobs, yaml.MapItem{Key: name, Value: job})
+		}
+	}
+
+	doc := yaml.MapSlice{
+		{Key: "stages", Value: stages},
+	}
+	if conf.Clone.Depth != 0 {
+		doc = append(doc, yaml.MapItem{
+			Key: "variables",
+			Value: yaml.MapSlice{
+				{Key: "GIT_DEPTH", Value: strconv.Itoa(conf.Clone.Depth)},
+			},
+		})
+	}
+	doc = append(doc, jobs...)
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	result.Files = append(result.Files, &File{
+		Name: ".gitlab-ci.yml",
+		Data: out,
+	})
+	return result, nil
+}
+
+// gitlabRulesFor returns the rules that select the pipeline. Rules
+// with an empty when clause apply the default when of the job. It
+// returns false if the pipeline cannot be selected in GitLab.
+func gitlabRulesFor(conf *bitbucket.Config, selector bitbucket.Selector, result *Result) ([]*gitlabRule, bool) {
+	switch selector.Section {
+	case bitbucket.SectionDefault:
+		// the default pipeline runs for every branch that does
+		// not match a branch specific pipeline.
+		var rules []*gitlabRule
+		for _, other := range conf.Selectors() {
+			if other.Section == bitbucket.SectionBranches {
+				rules = append(rules, &gitlabRule{
+					If:   fmt.Sprintf("$CI_COMMIT_BRANCH =~ /%s/", globRegexp(other.Pattern)),
+					When: "never",
+				})
+			}
+		}
+		rules = append(rules, &gitlabRule{
+			If: `$CI_COMMIT_BRANCH && $CI_PIPELINE_SOURCE == "push"`,
+		})
+		return rules, true
+	case bitbucket.SectionBranches:
+		return []*gitlabRule{{
+			If: fmt.Sprintf(`$CI_COMMIT_BRANCH =~ /%s/ && $CI_PIPELINE_SOURCE == "push"`, globRegexp(selector.Pattern)),
+		}}, true
+	case bitbucket.SectionTags:
+		return []*gitlabRule{{
+			If: fmt.Sprintf("$CI_COMMIT_TAG =~ /%s/", globRegexp(selector.Pattern)),
+		}}, true
+	case bitbucket.SectionPullRequests:
+		return []*gitlabRule{{
+			If: fmt.Sprintf(`$CI_PIPELINE_SOURCE == "merge_request_event" && $CI_MERGE_REQUEST_SOURCE_BRANCH_NAME =~ /%s/`, globRegexp(selector.Pattern)),
+		}}, true
+	case bitbucket.SectionCustom:
+		result.warnf(selector, "custom pipeline is selected by running a web pipeline with the variable PIPELINE=%s", selector.Pattern)
+		return []*gitlabRule{{
+			If: fmt.Sprintf(`$CI_PIPELINE_SOURCE == "web" && $PIPELINE == %q`, selector.Pattern),
+		}}, true
+	default:
+		result.warnf(selector, "%s pipelines are not supported, the pipeline is not converted", selector.Section)
+		return nil, false
+	}
+}
@@ -0,0 +1,116 @@
+package convert
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cncd/bitbucket-frontend"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGitLabGolden(t *testing.T) {
+	tests := map[string]string{
+		"full":        "testdata/full.yml",
+		"bitbucket_1": "../samples/bitbucket_1/bitbucket-pipelines.yml",
+	}
+	for name, file := range tests {
+		conf, err := bitbucket.ParseFile(file)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		result, err := GitLab(conf)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if want, got := ".gitlab-ci.yml", result.Files[0].Name; want != got {
+			t.Errorf("%s: wanted file name %s, got %s", name, want, got)
+		}
+
+		golden := filepath.Join("testdata", "gitlab", name+".golden")
+		if *update {
+			if err := ioutil.WriteFile(golden, result.Files[0].Data, 0644); err != nil {
+				t.Error(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if got := result.Files[0].Data; string(got) != string(want) {
+			t.Errorf("%s: output does not match %s, got\n%s", name, golden, got)
+		}
+	}
+}
+
+func TestGitLabWarnings(t *testing.T) {
+	conf, err := bitbucket.ParseFile("testdata/full.yml")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result, err := GitLab(conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	warnings := map[string]bool{}
+	for _, warning := range result.Warnings {
+		warnings[warning.String()] = true
+	}
+	for _, want := range []string{
+		"default: step test: service postgres: memory limit is not converted",
+		"branches/master: step deploy: docker service requires a runner with privileged mode enabled",
+		"bookmarks/stable: bookmarks pipelines are not supported, the pipeline is not converted",
+		"custom/nightly: custom pipeline is selected by running a web pipeline with the variable PIPELINE=nightly",
+	} {
+		if !warnings[want] {
+			t.Errorf("Expect warning %q", want)
+		}
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := map[string]string{
+		"master":          `^master$`,
+		"feature/*":       `^feature\/[^\/]*$`,
+		"**":              `^.*$`,
+		"release-?.{x,y}": `^release-[^\/]\.(x|y)$`,
+	}
+	for pattern, want := range tests {
+		if got := globRegexp(pattern); got != want {
+			t.Errorf("Wanted regexp %s for %s, got %s", want, pattern, got)
+		}
+	}
+}
+
+func TestCachePath(t *testing.T) {
+	conf := new(bitbucket.Config)
+	conf.Definitions.Caches = map[string]string{"node": "web/node_modules"}
+
+	tests := []struct {
+		name, path string
+		ok         bool
+	}{
+		{name: "node", path: "web/node_modules", ok: true},
+		{name: "maven", path: "~/.m2/repository", ok: true},
+		{name: "docker"},
+		{name: "bundler"},
+	}
+	for _, test := range tests {
+		path, err := cachePath(conf, test.name)
+		if ok := err == nil; ok != test.ok {
+			t.Errorf("Wanted cache %s ok %v, got error %v", test.name, test.ok, err)
+		}
+		if path != test.path {
+			t.Errorf("Wanted cache %s path %q, got %q", test.name, test.path, path)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package bitbucket
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+// kinds of changes between two compiled pipelines.
+const (
+	ChangeAdded       = "added"
+	ChangeRemoved     = "removed"
+	ChangeMoved       = "moved"
+	ChangeImage       = "image"
+	ChangeEnvironment = "environment"
+	ChangeScript      = "script"
+)
+
+// Change describes a semantic difference between two compiled
+// pipelines. Steps are identified by their alias.
+type Change struct {
+	Pipeline string `json:"pipeline,omitempty"`
+	Step     string `json:"step,omitempty"`
+	Kind     string `json:"kind"`
+	Key      string `json:"key,omitempty"`
+	Old      string `json:"old,omitempty"`
+	New      string `json:"new,omitempty"`
+}
+
+// String returns a readable description of the change.
+func (c *Change) String() string {
+	var prefix string
+	if c.Pipeline != "" {
+		prefix = c.Pipeline + ": "
+	}
+	if c.Step == "" {
+		return fmt.Sprintf("%spipeline %s", prefix, c.Kind)
+	}
+	prefix += c.Step + " "
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("%sadded (%s)", prefix, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("%sremoved (%s)", prefix, c.Old)
+	case ChangeMoved:
+		return fmt.Sprintf("%smoved from %s", prefix, c.Old)
+	case ChangeImage:
+		return fmt.Sprintf("%simage changed from %s to %s", prefix, c.Old, c.New)
+	case ChangeEnvironment:
+		switch {
+		case c.Old == "":
+			return fmt.Sprintf("%senvironment %s added: %q", prefix, c.Key, c.New)
+		case c.New == "":
+			return fmt.Sprintf("%senvironment %s removed: %q", prefix, c.Key, c.Old)
+		default:
+			return fmt.Sprintf("%senvironment %s changed from %q to %q", prefix, c.Key, c.Old, c.New)
+		}
+	case ChangeScript:
+		if c.Old != "" {
+			return fmt.Sprintf("%sscript - %s", prefix, c.Old)
+		}
+		return fmt.Sprintf("%sscript + %s", prefix, c.New)
+	default:
+		return prefix + c.Kind
+	}
+}
+
+// DiffAll compares the compiled pipelines by selector and returns the
+// changes sorted by selector. Pipelines that only exist on one side
+// are reported as added or removed.
+func DiffAll(from, to map[Selector]*backend.Config) []*Change {
+	var selectors []Selector
+	seen := map[Selector]bool{}
+	for _, pipelines := range []map[Selector]*backend.Config{from, to} {
+		for selector := range pipelines {
+			if !seen[selector] {
+				seen[selector] = true
+				selectors = append(selectors, selector)
+			}
+		}
+	}
+	sort.Slice(selectors, func(i, j int) bool {
+		return selectors[i].String() < selectors[j].String()
+	})
+
+	var changes []*Change
+	for _, selector := range selectors {
+		a, inFrom := from[selector]
+		b, inTo := to[selector]
+		switch {
+		case !inFrom:
+			changes = append(changes, &Change{Pipeline: selector.String(), Kind: ChangeAdded})
+		case !inTo:
+			changes = append(changes, &Change{Pipeline: selector.String(), Kind: ChangeRemoved})
+		default:
+			for _, change := range Diff(a, b) {
+				change.Pipeline = selector.String()
+				changes = append(changes, change)
+			}
+		}
+	}
+	return changes
+}
+
+// Diff compares two compiled pipelines. Steps are matched by their
+// build script so that reordered steps are reported as moved rather
+// than changed. Steps without a matching script are paired in order,
+// and the remaining steps are reported as added or removed.
+func Diff(from, to *backend.Config) []*Change {
+	a, b := flattenSteps(from), flattenSteps(to)
+
+	// pair the steps with identical scripts in order.
+	pairs := map[int]int{}
+	paired := map[int]bool{}
+	for i, step := range a {
+		for j, other := range b {
+			if !paired[j] && step.Environment["CI_SCRIPT"] == other.Environment["CI_SCRIPT"] {
+				pairs[i] = j
+				paired[j] = true
+				break
+			}
+		}
+	}
+
+	// pair the remaining steps in order.
+	for i := range a {
+		if _, ok := pairs[i]; ok {
+			continue
+		}
+		for j := range b {
+			if !paired[j] {
+				pairs[i] = j
+				paired[j] = true
+				break
+			}
+		}
+	}
+
+	// paired steps that are not part of the longest sequence
+	// in common order have moved.
+	var order []int
+	for i := range a {
+		if j, ok := pairs[i]; ok {
+			order = append(order, j)
+		}
+	}
+	inOrder := longestIncreasing(order)
+
+	var changes []*Change
+	for i, step := range a {
+		j, ok := pairs[i]
+		if !ok {
+			changes = append(changes, &Change{Step: step.Alias, Kind: ChangeRemoved, Old: step.Image})
+			continue
+		}
+		other := b[j]
+		if !inOrder[j] {
+			changes = append(changes, &Change{Step: other.Alias, Kind: ChangeMoved, Old: step.Alias, New: other.Alias})
+		}
+		changes = append(changes, diffStep(step, other)...)
+	}
+	for j, step := range b {
+		if !paired[j] {
+			changes = append(changes, &Change{Step: step.Alias, Kind: ChangeAdded, New: step.Image})
+		}
+	}
+	return changes
+}
+
+// diffStep compares the image, environment and script of two steps.
+func diffStep(from, to *backend.Step) []*Change {
+	var changes []*Change
+	if from.Image != to.Image {
+		changes = append(changes, &Change{Step: to.Alias, Kind: ChangeImage, Old: from.Image, New: to.Image})
+	}
+
+	var keys []string
+	for key := range from.Environment {
+		keys = append(keys, key)
+	}
+	for key := range to.Environment {
+		if _, ok := from.Environment[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "CI_SCRIPT" {
+			continue
+		}
+		if a, b := from.Environment[key], to.Environment[key]; a != b {
+			changes = append(changes, &Change{Step: to.Alias, Kind: ChangeEnvironment, Key: key, Old: a, New: b})
+		}
+	}
+
+	a := scriptLines(from.Environment["CI_SCRIPT"])
+	b := scriptLines(to.Environment["CI_SCRIPT"])
+	for _, line := range diffLines(a, b) {
+		line.Step = to.Alias
+		changes = append(changes, line)
+	}
+	return changes
+}
+
+// flattenSteps returns the steps of every stage of the pipeline.
+func flattenSteps(spec *backend.Config) []*backend.Step {
+	var steps []*backend.Step
+	for _, stage := range spec.Stages {
+		steps = append(steps, stage.Steps...)
+	}
+	return steps
+}
+
+// scriptLines returns the non-empty lines of the encoded build script,
+// excluding the lines that trace the commands.
+func scriptLines(encoded string) []string {
+	decoded, _ := base64.StdEncoding.DecodeString(encoded)
+	var lines []string
+	for _, line := range strings.Split(string(decoded), "\n") {
+		if line == "" || strings.HasPrefix(line, "echo + ") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// diffLines returns the lines removed from a and added to b.
+func diffLines(a, b []string) []*Change {
+	var changes []*Change
+	for _, edit := range editLines(a, b) {
+		switch edit[0] {
+		case '-':
+			changes = append(changes, &Change{Kind: ChangeScript, Old: edit[1:]})
+		case '+':
+			changes = append(changes, &Change{Kind: ChangeScript, New: edit[1:]})
+		}
+	}
+	return changes
+}
+
+// DiffText returns the lines removed from a and added to b, prefixed
+// with - and + respectively, with the number of unchanged lines around
+// each difference prefixed with a space. Each group of differences is
+// preceded by a line with its position in b.
+func DiffText(a, b []byte, context int) []string {
+	edits := editLines(
+		strings.Split(strings.TrimSuffix(string(a), "\n"), "\n"),
+		strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"),
+	)
+
+	var out []string
+	line, last := 1, -1
+	for k, edit := range edits {
+		if nearEdit(edits, k, context) {
+			if last == -1 || k != last+1 {
+				out = append(out, fmt.Sprintf("@@ line %d @@", line))
+			}
+			out = append(out, edit)
+			last = k
+		}
+		if edit[0] != '-' {
+			line++
+		}
+	}
+	return out
+}
+
+// editLines returns the edits turning the lines of a into the lines of
+// b, based on the longest common subsequence of the lines. Each edit is
+// a line prefixed with a space if unchanged, - if removed or + if added.
+func editLines(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var edits []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, " "+a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, "-"+a[i])
+			i++
+		default:
+			edits = append(edits, "+"+b[j])
+			j++
+		}
+	}
+	return edits
+}
+
+// nearEdit returns true if an edit within the context of the edit at
+// the index removes or adds a line.
+func nearEdit(edits []string, index, context int) bool {
+	for k := index - context; k <= index+context; k++ {
+		if k >= 0 && k < len(edits) && edits[k][0] != ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+// longestIncreasing returns the set of values that are part of the
+// longest increasing subsequence of the values.
+func longestIncreasing(values []int) map[int]bool {
+	length := make([]int, len(values))
+	prev := make([]int, len(values))
+	best := -1
+	for i := range values {
+		length[i], prev[i] = 1, -1
+		for k := 0; k < i; k++ {
+			if values[k] < values[i] && length[k]+1 > length[i] {
+				length[i], prev[i] = length[k]+1, k
+			}
+		}
+		if best == -1 || length[i] > length[best] {
+			best = i
+		}
+	}
+	set := map[int]bool{}
+	for i := best; i != -1; i = prev[i] {
+		set[values[i]] = true
+	}
+	return set
+}
@@ -0,0 +1,155 @@
+package bitbucket
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	from, err := ParseString(diffOldYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	to, err := ParseString(diffNewYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	compiler := NewCompiler(WithPrefix("test"), WithLocal(true))
+	a, err := compiler.CompileAll(from)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, err := compiler.CompileAll(to)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	changes := DiffAll(a, b)
+
+	want := []string{
+		"branches/master: pipeline added",
+		"default: step_1 image changed from golang:1.8 to golang:1.9",
+		"default: step_1 script - go build",
+		"default: step_1 script + go build -race",
+		"default: step_0 moved from step_1",
+		"default: step_2 removed (node:latest)",
+		"tags/v*: pipeline removed",
+	}
+	var got []string
+	for _, change := range changes {
+		got = append(got, change.String())
+	}
+	if len(want) != len(got) {
+		t.Errorf("Wanted %d changes, got %d: %q", len(want), len(got), got)
+		return
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			t.Errorf("Wanted change %q, got %q", want[i], got[i])
+		}
+	}
+}
+
+func TestDiffEnvironment(t *testing.T) {
+	config, err := ParseString(diffOldYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	from, err := NewCompiler(WithLocal(true), WithEnviron(map[string]string{"GOOS": "linux"})).Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	to, err := NewCompiler(WithLocal(true), WithEnviron(map[string]string{"GOARCH": "arm64", "GOOS": "darwin"})).Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	changes := Diff(from, to)
+	if want, got := 6, len(changes); want != got {
+		t.Errorf("Wanted %d changes, got %d", want, got)
+		return
+	}
+	if want, got := `step_0 environment GOARCH added: "arm64"`, changes[0].String(); want != got {
+		t.Errorf("Wanted change %q, got %q", want, got)
+	}
+	if want, got := `step_0 environment GOOS changed from "linux" to "darwin"`, changes[1].String(); want != got {
+		t.Errorf("Wanted change %q, got %q", want, got)
+	}
+	if len(Diff(from, from)) != 0 {
+		t.Errorf("Expect no changes between identical pipelines")
+	}
+}
+
+var diffOldYaml = `
+pipelines:
+  default:
+    - step:
+        image: golang:1.8
+        script: [ go build ]
+    - step:
+        image: golang:1.8
+        script: [ go test ]
+    - step:
+        image: node
+        script: [ npm test ]
+  tags:
+    v*:
+      - step:
+          script: [ make release ]
+`
+
+var diffNewYaml = `
+pipelines:
+  default:
+    - step:
+        image: golang:1.8
+        script: [ go test ]
+    - step:
+        image: golang:1.9
+        script: [ go build -race ]
+  branches:
+    master:
+      - step:
+          script: [ make ]
+`
+
+func TestDiffText(t *testing.T) {
+	a := []byte("a\nb\nc\nd\ne\nf\ng\nh\n")
+	b := []byte("a\nB\nc\nd\ne\nf\ng\nh\ni\n")
+
+	tests := []struct {
+		context int
+		want    []string
+	}{
+		{
+			context: 0,
+			want:    []string{"@@ line 2 @@", "-b", "+B", "@@ line 9 @@", "+i"},
+		},
+		{
+			context: 1,
+			want:    []string{"@@ line 1 @@", " a", "-b", "+B", " c", "@@ line 8 @@", " h", "+i"},
+		},
+		{
+			context: 3,
+			want:    []string{"@@ line 1 @@", " a", "-b", "+B", " c", " d", " e", " f", " g", " h", "+i"},
+		},
+	}
+	for _, test := range tests {
+		got := DiffText(a, b, test.context)
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("Wanted diff with context %d %q, got %q", test.context, test.want, got)
+		}
+	}
+
+	if got := DiffText(a, a, 3); len(got) != 0 {
+		t.Errorf("Wanted no diff of equal text, got %q", got)
+	}
+}
@@ -0,0 +1,90 @@
+package bitbucket
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// Diagnostic describes a problem found in the configuration that does
+// not prevent the configuration from being compiled.
+type Diagnostic struct {
+	Pipeline string `json:"pipeline,omitempty"`
+	Step     string `json:"step,omitempty"`
+	Message  string `json:"message"`
+}
+
+// String returns the diagnostic prefixed with the pipeline selector
+// and the step.
+func (d *Diagnostic) String() string {
+	prefix := ""
+	if d.Pipeline != "" {
+		prefix += d.Pipeline + ": "
+	}
+	if d.Step != "" {
+		prefix += d.Step + ": "
+	}
+	return prefix + d.Message
+}
+
+// Lint checks the configuration for problems such as references to
+// undefined services and caches, steps without commands and invalid
+// patterns.
+func Lint(conf *Config) []*Diagnostic {
+	var diagnostics []*Diagnostic
+
+	var services []string
+	for name := range conf.Definitions.Services {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+	for _, name := range services {
+		if conf.Definitions.Services[name].Image == "" {
+			diagnostics = append(diagnostics, &Diagnostic{
+				Message: fmt.Sprintf("service %q has no image", name),
+			})
+		}
+	}
+
+	for _, selector := range conf.Selectors() {
+		stage, _ := conf.Lookup(selector)
+		lint := func(step, format string, args ...interface{}) {
+			diagnostics = append(diagnostics, &Diagnostic{
+				Pipeline: selector.String(),
+				Step:     step,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		switch selector.Section {
+		case SectionDefault, SectionCustom:
+		default:
+			if _, err := path.Match(selector.Pattern, ""); err != nil {
+				lint("", "invalid pattern %q", selector.Pattern)
+			}
+		}
+		if len(stage.Steps) == 0 {
+			lint("", "pipeline has no steps")
+		}
+
+		for i, step := range stage.Steps {
+			alias := fmt.Sprintf("step_%d", i)
+			if len(step.Script) == 0 {
+				lint(alias, "step has no script")
+			}
+			for _, name := range step.Services {
+				if _, ok := conf.Definitions.Services[name]; !ok && name != "docker" {
+					lint(alias, "service %q is not defined", name)
+				}
+			}
+			for _, name := range step.Caches {
+				_, defined := conf.Definitions.Caches[name]
+				_, predefined := PredefinedCaches[name]
+				if !defined && !predefined {
+					lint(alias, "cache %q is not defined", name)
+				}
+			}
+		}
+	}
+	return diagnostics
+}
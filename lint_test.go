@@ -0,0 +1,64 @@
+package bitbucket
+
+import (
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	config, err := ParseString(lintYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	want := []string{
+		`service "mysql" has no image`,
+		`default: step_0: service "redis" is not defined`,
+		`default: step_0: cache "bundler" is not defined`,
+		`default: step_1: step has no script`,
+		`branches/[master: invalid pattern "[master"`,
+		`branches/[master: pipeline has no steps`,
+	}
+	got := Lint(config)
+	if len(want) != len(got) {
+		t.Errorf("Wanted %d diagnostics, got %d: %v", len(want), len(got), got)
+		return
+	}
+	for i := range want {
+		if want[i] != got[i].String() {
+			t.Errorf("Wanted diagnostic %q, got %q", want[i], got[i])
+		}
+	}
+}
+
+func TestLintValid(t *testing.T) {
+	config, err := ParseString(sectionsYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got := Lint(config); len(got) != 0 {
+		t.Errorf("Wanted no diagnostics, got %v", got)
+	}
+}
+
+var lintYaml = `
+pipelines:
+  default:
+    - step:
+        services: [ docker, redis, mysql ]
+        caches: [ node, docker, bundler, vendor ]
+        script: [ make ]
+    - step:
+        image: golang
+  branches:
+    "[master": []
+
+definitions:
+  caches:
+    vendor: vendor
+  services:
+    mysql:
+      variables:
+        MYSQL_DATABASE: test
+`
@@ -0,0 +1,93 @@
+package bitbucket
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// output formats of the compiled configuration.
+const (
+	FormatJSON        = "json"
+	FormatJSONCompact = "json-compact"
+	FormatYAML        = "yaml"
+)
+
+// MarshalOption configures a marshal option.
+type MarshalOption func(*marshaler)
+
+// marshaler holds the marshal options.
+type marshaler struct {
+	decode bool
+}
+
+// WithDecodedScript configures the yaml output to include the
+// CI_SCRIPT environment variable as a readable block of text
+// instead of base64 encoded. The output is intended for review
+// and cannot be executed.
+func WithDecodedScript(decode bool) MarshalOption {
+	return func(m *marshaler) {
+		m.decode = decode
+	}
+}
+
+// Marshal serialises the compiled pipeline configuration, or a map of
+// compiled pipeline configurations, in the named format. The keys of
+// the yaml output are sorted.
+func Marshal(v interface{}, format string, opts ...MarshalOption) ([]byte, error) {
+	m := new(marshaler)
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	switch format {
+	case FormatJSON, "":
+		return json.MarshalIndent(v, "", "  ")
+	case FormatJSONCompact:
+		return json.Marshal(v)
+	case FormatYAML:
+		// the value is converted to generic maps using the json
+		// encoding so the yaml output uses the json field names
+		// and the yaml encoder sorts the keys.
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var out interface{}
+		err = json.Unmarshal(raw, &out)
+		if err != nil {
+			return nil, err
+		}
+		if m.decode {
+			decodeScripts(out)
+		}
+		return yaml.Marshal(out)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// decodeScripts walks the generic representation of the compiled
+// configuration and decodes every base64 CI_SCRIPT variable.
+func decodeScripts(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, vv := range v {
+			script, ok := vv.(string)
+			if k != "CI_SCRIPT" || !ok {
+				decodeScripts(vv)
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(script)
+			if err == nil {
+				v[k] = string(decoded)
+			}
+		}
+	case []interface{}:
+		for _, vv := range v {
+			decodeScripts(vv)
+		}
+	}
+}
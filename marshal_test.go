@@ -0,0 +1,82 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestMarshal(t *testing.T) {
+	config, err := ParseString(sample)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	compiled, err := NewCompiler(WithPrefix("test")).Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	out, err := Marshal(compiled, FormatJSONCompact)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if strings.Contains(string(out), "\n") {
+		t.Errorf("Expect compact json on a single line")
+	}
+
+	out, err = Marshal(compiled, FormatYAML)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(out, &doc); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := doc["pipeline"]; !ok {
+		t.Errorf("Expect yaml output to use the json field names")
+	}
+
+	again, _ := Marshal(compiled, FormatYAML)
+	if string(out) != string(again) {
+		t.Errorf("Expect deterministic yaml output")
+	}
+
+	if _, err := Marshal(compiled, "toml"); err == nil {
+		t.Errorf("Expect error for unsupported format")
+	}
+}
+
+func TestMarshalDecodedScript(t *testing.T) {
+	config, err := ParseString(sample)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	compiled, err := NewCompiler(WithPrefix("test")).Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	out, err := Marshal(compiled, FormatYAML, WithDecodedScript(true))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !strings.Contains(string(out), "echo + \"go build\"") {
+		t.Errorf("Expect decoded script in yaml output")
+	}
+
+	// the compiled configuration is not modified.
+	raw, _ := json.Marshal(compiled)
+	if strings.Contains(string(raw), "go build") {
+		t.Errorf("Expect script to remain encoded in the compiled configuration")
+	}
+}
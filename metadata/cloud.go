@@ -0,0 +1,200 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+// Bitbucket Cloud webhook event keys, sent in the X-Event-Key header.
+const (
+	CloudEventPush             = "repo:push"
+	CloudEventPullRequestOpen  = "pullrequest:created"
+	CloudEventPullRequestPatch = "pullrequest:updated"
+)
+
+type (
+	cloudLink struct {
+		Href string `json:"href"`
+	}
+
+	cloudLinks struct {
+		HTML   cloudLink `json:"html"`
+		Avatar cloudLink `json:"avatar"`
+	}
+
+	cloudUser struct {
+		DisplayName string     `json:"display_name"`
+		Links       cloudLinks `json:"links"`
+	}
+
+	cloudRepository struct {
+		FullName  string     `json:"full_name"`
+		IsPrivate bool       `json:"is_private"`
+		Links     cloudLinks `json:"links"`
+	}
+
+	cloudCommit struct {
+		Hash    string    `json:"hash"`
+		Message string    `json:"message"`
+		Date    time.Time `json:"date"`
+		Author  struct {
+			Raw  string    `json:"raw"`
+			User cloudUser `json:"user"`
+		} `json:"author"`
+		Links cloudLinks `json:"links"`
+	}
+
+	cloudPushHook struct {
+		Repository cloudRepository `json:"repository"`
+		Push       struct {
+			Changes []struct {
+				New *struct {
+					Type   string      `json:"type"`
+					Name   string      `json:"name"`
+					Target cloudCommit `json:"target"`
+				} `json:"new"`
+			} `json:"changes"`
+		} `json:"push"`
+	}
+
+	cloudPullRequestHook struct {
+		Repository  cloudRepository `json:"repository"`
+		PullRequest struct {
+			Title       string        `json:"title"`
+			Author      cloudUser     `json:"author"`
+			Source      cloudEndpoint `json:"source"`
+			Destination cloudEndpoint `json:"destination"`
+			Links       cloudLinks    `json:"links"`
+			UpdatedOn   time.Time     `json:"updated_on"`
+		} `json:"pullrequest"`
+	}
+
+	cloudEndpoint struct {
+		Branch struct {
+			Name string `json:"name"`
+		} `json:"branch"`
+		Commit struct {
+			Hash string `json:"hash"`
+		} `json:"commit"`
+		Repository cloudRepository `json:"repository"`
+	}
+)
+
+// FromCloudWebhook returns the metadata of the builds triggered by the
+// Bitbucket Cloud webhook payload with the event key. A push returns
+// the metadata of every branch and tag change in the push, excluding
+// deleted branches and tags. A pull request returns the metadata of
+// the source commit, with the destination branch as the target.
+func FromCloudWebhook(eventKey string, payload []byte) ([]frontend.Metadata, error) {
+	switch eventKey {
+	case CloudEventPush:
+		hook := new(cloudPushHook)
+		if err := json.Unmarshal(payload, hook); err != nil {
+			return nil, fmt.Errorf("invalid %s payload: %s", eventKey, err)
+		}
+		return cloudPush(hook), nil
+	case CloudEventPullRequestOpen, CloudEventPullRequestPatch:
+		hook := new(cloudPullRequestHook)
+		if err := json.Unmarshal(payload, hook); err != nil {
+			return nil, fmt.Errorf("invalid %s payload: %s", eventKey, err)
+		}
+		return []frontend.Metadata{cloudPullRequest(hook)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported event key %q", eventKey)
+	}
+}
+
+// cloudPush returns the metadata of the changes of the push.
+func cloudPush(hook *cloudPushHook) []frontend.Metadata {
+	var out []frontend.Metadata
+	for _, change := range hook.Push.Changes {
+		if change.New == nil {
+			continue
+		}
+		m := frontend.Metadata{Repo: cloudRepo(hook.Repository)}
+		commit := change.New.Target
+		m.Curr.Created = commit.Date.Unix()
+		m.Curr.Link = commit.Links.HTML.Href
+		m.Curr.Commit.Sha = commit.Hash
+		m.Curr.Commit.Message = strings.TrimSpace(commit.Message)
+		m.Curr.Commit.Author = cloudAuthor(commit)
+
+		switch change.New.Type {
+		case "tag", "annotated_tag":
+			m.Curr.Event = frontend.EventTag
+			m.Curr.Commit.Ref = "refs/tags/" + change.New.Name
+		default:
+			m.Curr.Event = frontend.EventPush
+			m.Curr.Commit.Ref = "refs/heads/" + change.New.Name
+			m.Curr.Commit.Branch = change.New.Name
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// cloudPullRequest returns the metadata of the pull request.
+func cloudPullRequest(hook *cloudPullRequestHook) frontend.Metadata {
+	pr := hook.PullRequest
+	m := frontend.Metadata{Repo: cloudRepo(hook.Repository)}
+	m.Curr.Event = frontend.EventPull
+	m.Curr.Created = pr.UpdatedOn.Unix()
+	m.Curr.Link = pr.Links.HTML.Href
+	m.Curr.Target = pr.Destination.Branch.Name
+	m.Curr.Commit.Sha = pr.Source.Commit.Hash
+	m.Curr.Commit.Ref = "refs/heads/" + pr.Source.Branch.Name
+	m.Curr.Commit.Refspec = pr.Source.Branch.Name + ":" + pr.Destination.Branch.Name
+	m.Curr.Commit.Branch = pr.Source.Branch.Name
+	m.Curr.Commit.Message = pr.Title
+	m.Curr.Commit.Author.Name = pr.Author.DisplayName
+	m.Curr.Commit.Author.Avatar = pr.Author.Links.Avatar.Href
+
+	// pull requests from forks are cloned from the source
+	// repository.
+	if source := pr.Source.Repository; source.FullName != "" && source.FullName != hook.Repository.FullName {
+		m.Repo.Remote = cloudRemote(source)
+	}
+	return m
+}
+
+// cloudRepo returns the repository metadata.
+func cloudRepo(repo cloudRepository) frontend.Repo {
+	return frontend.Repo{
+		Name:    repo.FullName,
+		Link:    repo.Links.HTML.Href,
+		Remote:  cloudRemote(repo),
+		Private: repo.IsPrivate,
+	}
+}
+
+// cloudRemote returns the clone url of the repository.
+func cloudRemote(repo cloudRepository) string {
+	link := repo.Links.HTML.Href
+	if link == "" {
+		link = "https://bitbucket.org/" + repo.FullName
+	}
+	return link + ".git"
+}
+
+// cloudAuthor returns the author of the commit. The name and email are
+// parsed from the raw author in the format "Name <email>".
+func cloudAuthor(commit cloudCommit) frontend.Author {
+	author := frontend.Author{
+		Name:   commit.Author.User.DisplayName,
+		Avatar: commit.Author.User.Links.Avatar.Href,
+	}
+	raw := commit.Author.Raw
+	if i, j := strings.Index(raw, "<"), strings.LastIndex(raw, ">"); i != -1 && j > i {
+		author.Email = raw[i+1 : j]
+		if author.Name == "" {
+			author.Name = strings.TrimSpace(raw[:i])
+		}
+	} else if author.Name == "" {
+		author.Name = strings.TrimSpace(raw)
+	}
+	return author
+}
@@ -0,0 +1,127 @@
+package metadata
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+func TestCloudPush(t *testing.T) {
+	payload, err := ioutil.ReadFile("testdata/cloud/repo_push.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := FromCloudWebhook(CloudEventPush, payload)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the deleted branch is skipped.
+	if want, got := 2, len(out); want != got {
+		t.Errorf("Wanted %d builds, got %d", want, got)
+		return
+	}
+
+	branch, tag := out[0], out[1]
+	for _, test := range []struct{ name, want, got string }{
+		{"event", frontend.EventPush, branch.Curr.Event},
+		{"ref", "refs/heads/master", branch.Curr.Commit.Ref},
+		{"branch", "master", branch.Curr.Commit.Branch},
+		{"sha", "762941318ee16e59dabbacb1b4049eec22f0d303", branch.Curr.Commit.Sha},
+		{"message", "Update the readme", branch.Curr.Commit.Message},
+		{"author", "Octo Cat", branch.Curr.Commit.Author.Name},
+		{"email", "octocat@example.com", branch.Curr.Commit.Author.Email},
+		{"avatar", "https://avatar.example.com/octocat.png", branch.Curr.Commit.Author.Avatar},
+		{"link", "https://bitbucket.org/octocat/hello-world/commits/762941318ee16e59dabbacb1b4049eec22f0d303", branch.Curr.Link},
+		{"repo", "octocat/hello-world", branch.Repo.Name},
+		{"remote", "https://bitbucket.org/octocat/hello-world.git", branch.Repo.Remote},
+		{"tag event", frontend.EventTag, tag.Curr.Event},
+		{"tag ref", "refs/tags/v1.0.0", tag.Curr.Commit.Ref},
+		{"tag branch", "", tag.Curr.Commit.Branch},
+		{"tag author", "Jane Doe", tag.Curr.Commit.Author.Name},
+		{"tag email", "jane@example.com", tag.Curr.Commit.Author.Email},
+	} {
+		if test.want != test.got {
+			t.Errorf("Wanted %s %q, got %q", test.name, test.want, test.got)
+		}
+	}
+	if !branch.Repo.Private {
+		t.Errorf("Expect private repository")
+	}
+	if want, got := int64(1486119586), branch.Curr.Created; want != got {
+		t.Errorf("Wanted created %d, got %d", want, got)
+	}
+}
+
+func TestCloudPullRequest(t *testing.T) {
+	tests := []struct {
+		file, event                                string
+		sha, ref, refspec, branch, target, message string
+		remote                                     string
+	}{
+		{
+			file:    "testdata/cloud/pullrequest_created.json",
+			event:   CloudEventPullRequestOpen,
+			sha:     "d3b07384d113",
+			ref:     "refs/heads/feature/greeting",
+			refspec: "feature/greeting:master",
+			branch:  "feature/greeting",
+			target:  "master",
+			message: "Add the greeting",
+			remote:  "https://bitbucket.org/janedoe/hello-world.git",
+		},
+		{
+			file:    "testdata/cloud/pullrequest_updated.json",
+			event:   CloudEventPullRequestPatch,
+			sha:     "a1b2c3d4e5f6",
+			ref:     "refs/heads/bugfix/build",
+			refspec: "bugfix/build:develop",
+			branch:  "bugfix/build",
+			target:  "develop",
+			message: "Fix the build",
+			remote:  "https://bitbucket.org/octocat/hello-world.git",
+		},
+	}
+	for _, test := range tests {
+		payload, err := ioutil.ReadFile(test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := FromCloudWebhook(test.event, payload)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if want, got := 1, len(out); want != got {
+			t.Errorf("Wanted %d builds, got %d", want, got)
+			continue
+		}
+		m := out[0]
+		for _, field := range []struct{ name, want, got string }{
+			{"event", frontend.EventPull, m.Curr.Event},
+			{"sha", test.sha, m.Curr.Commit.Sha},
+			{"ref", test.ref, m.Curr.Commit.Ref},
+			{"refspec", test.refspec, m.Curr.Commit.Refspec},
+			{"branch", test.branch, m.Curr.Commit.Branch},
+			{"target", test.target, m.Curr.Target},
+			{"message", test.message, m.Curr.Commit.Message},
+			{"remote", test.remote, m.Repo.Remote},
+			{"repo", "octocat/hello-world", m.Repo.Name},
+		} {
+			if field.want != field.got {
+				t.Errorf("%s: wanted %s %q, got %q", test.file, field.name, field.want, field.got)
+			}
+		}
+	}
+}
+
+func TestCloudUnsupported(t *testing.T) {
+	if _, err := FromCloudWebhook("repo:fork", []byte("{}")); err == nil {
+		t.Errorf("Expect error for unsupported event key")
+	}
+	if _, err := FromCloudWebhook(CloudEventPush, []byte("{")); err == nil {
+		t.Errorf("Expect error for invalid payload")
+	}
+}
@@ -0,0 +1,120 @@
+// Package metadata derives the build metadata from sources such as the
+// local git repository and Bitbucket webhook payloads.
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+// FromGit returns the metadata of the commit checked out in the git
+// repository enclosing the directory. The ref is the current branch,
+// or the first tag pointing at the commit if the HEAD is detached.
+func FromGit(dir string) (frontend.Metadata, error) {
+	var m frontend.Metadata
+
+	out, err := git(dir, "log", "-1", "--format=%H%n%an%n%ae%n%ct%n%B")
+	if err != nil {
+		return m, err
+	}
+	lines := strings.SplitN(out, "\n", 5)
+	if len(lines) < 4 {
+		return m, fmt.Errorf("git: unexpected log output %q", out)
+	}
+	m.Curr.Commit.Sha = lines[0]
+	m.Curr.Commit.Author.Name = lines[1]
+	m.Curr.Commit.Author.Email = lines[2]
+	m.Curr.Created, _ = strconv.ParseInt(lines[3], 10, 64)
+	if len(lines) == 5 {
+		m.Curr.Commit.Message = strings.TrimSpace(lines[4])
+	}
+
+	// the branch is empty if the HEAD is detached.
+	branch, _ := git(dir, "symbolic-ref", "--short", "-q", "HEAD")
+	tags, err := git(dir, "tag", "--points-at", "HEAD")
+	if err != nil {
+		return m, err
+	}
+	switch {
+	case branch != "":
+		m.Curr.Event = frontend.EventPush
+		m.Curr.Commit.Branch = branch
+		m.Curr.Commit.Ref = "refs/heads/" + branch
+	case tags != "":
+		tag := strings.SplitN(tags, "\n", 2)[0]
+		m.Curr.Event = frontend.EventTag
+		m.Curr.Commit.Ref = "refs/tags/" + tag
+	}
+
+	// the remote is optional, for example in a new repository.
+	if remote, _ := git(dir, "config", "--get", "remote.origin.url"); remote != "" {
+		remote = stripCredentials(remote)
+		m.Repo.Remote = remote
+		m.Repo.Name, m.Repo.Link = parseRemote(remote)
+	}
+	return m, nil
+}
+
+// git runs the git command in the directory and returns the trimmed
+// output.
+func git(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// stripCredentials removes the user and password of an http or https
+// remote url, and the password of any other remote url, so that tokens
+// embedded in the remote do not end up in the pipeline environment.
+func stripCredentials(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.Host == "" || u.User == nil {
+		return remote
+	}
+	switch u.Scheme {
+	case "http", "https":
+		u.User = nil
+	default:
+		u.User = url.User(u.User.Username())
+	}
+	return u.String()
+}
+
+// parseRemote returns the full name and the web link of the repository
+// from the remote url, which is either a url or an scp-like address
+// such as git@bitbucket.org:owner/name.git.
+func parseRemote(remote string) (name, link string) {
+	var host, path string
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if i := strings.Index(remote, ":"); i != -1 {
+		host, path = remote[:i], remote[i+1:]
+		if j := strings.Index(host, "@"); j != -1 {
+			host = host[j+1:]
+		}
+	} else {
+		return "", ""
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 {
+		return path, ""
+	}
+	name = strings.Join(parts[len(parts)-2:], "/")
+	return name, "https://" + host + "/" + name
+}
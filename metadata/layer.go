@@ -0,0 +1,147 @@
+package metadata
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+// default system metadata.
+const (
+	DefaultSystemName = "pipec"
+	DefaultSystemLink = "https://github.com/cncd/pipec"
+	DefaultSystemArch = "linux/amd64"
+)
+
+// Field is a metadata field that can be set from a string value, such
+// as a command line flag or an environment variable.
+type Field struct {
+	// Name is the name of the field, which is also the name of the
+	// command line flag setting the field.
+	Name string
+
+	// EnvVar is the environment variable setting the field.
+	EnvVar string
+
+	// Default is the value of the field if it is empty after the
+	// values are layered.
+	Default string
+
+	value func(*frontend.Metadata) interface{}
+}
+
+// Fields are the metadata fields that can be set from string values.
+var Fields = []*Field{
+	{Name: "system-arch", EnvVar: "CI_SYSTEM_ARCH", Default: DefaultSystemArch, value: func(m *frontend.Metadata) interface{} { return &m.Sys.Arch }},
+	{Name: "system-name", EnvVar: "CI_SYSTEM_NAME", Default: DefaultSystemName, value: func(m *frontend.Metadata) interface{} { return &m.Sys.Name }},
+	{Name: "system-link", EnvVar: "CI_SYSTEM_LINK", Default: DefaultSystemLink, value: func(m *frontend.Metadata) interface{} { return &m.Sys.Link }},
+
+	{Name: "repo-name", EnvVar: "CI_REPO_NAME", value: func(m *frontend.Metadata) interface{} { return &m.Repo.Name }},
+	{Name: "repo-link", EnvVar: "CI_REPO_LINK", value: func(m *frontend.Metadata) interface{} { return &m.Repo.Link }},
+	{Name: "repo-remote-url", EnvVar: "CI_REPO_REMOTE", value: func(m *frontend.Metadata) interface{} { return &m.Repo.Remote }},
+	{Name: "repo-private", EnvVar: "CI_REPO_PRIVATE", value: func(m *frontend.Metadata) interface{} { return &m.Repo.Private }},
+
+	{Name: "build-number", EnvVar: "CI_BUILD_NUMBER", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Number }},
+	{Name: "build-created", EnvVar: "CI_BUILD_CREATED", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Created }},
+	{Name: "build-started", EnvVar: "CI_BUILD_STARTED", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Started }},
+	{Name: "build-finished", EnvVar: "CI_BUILD_FINISHED", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Finished }},
+	{Name: "build-status", EnvVar: "CI_BUILD_STATUS", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Status }},
+	{Name: "build-event", EnvVar: "CI_BUILD_EVENT", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Event }},
+	{Name: "build-link", EnvVar: "CI_BUILD_LINK", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Link }},
+	{Name: "build-target", EnvVar: "CI_BUILD_TARGET", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Target }},
+	{Name: "commit-sha", EnvVar: "CI_COMMIT_SHA", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Commit.Sha }},
+	{Name: "commit-ref", EnvVar: "CI_COMMIT_REF", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Commit.Ref }},
+	{Name: "commit-refspec", EnvVar: "CI_COMMIT_REFSPEC", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Commit.Refspec }},
+	{Name: "commit-branch", EnvVar: "CI_COMMIT_BRANCH", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Commit.Branch }},
+	{Name: "commit-message", EnvVar: "CI_COMMIT_MESSAGE", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Commit.Message }},
+	{Name: "commit-author-name", EnvVar: "CI_COMMIT_AUTHOR_NAME", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Commit.Author.Name }},
+	{Name: "commit-author-avatar", EnvVar: "CI_COMMIT_AUTHOR_AVATAR", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Commit.Author.Avatar }},
+	{Name: "commit-author-email", EnvVar: "CI_COMMIT_AUTHOR_EMAIL", value: func(m *frontend.Metadata) interface{} { return &m.Curr.Commit.Author.Email }},
+
+	{Name: "prev-build-number", EnvVar: "CI_PREV_BUILD_NUMBER", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Number }},
+	{Name: "prev-build-created", EnvVar: "CI_PREV_BUILD_CREATED", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Created }},
+	{Name: "prev-build-started", EnvVar: "CI_PREV_BUILD_STARTED", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Started }},
+	{Name: "prev-build-finished", EnvVar: "CI_PREV_BUILD_FINISHED", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Finished }},
+	{Name: "prev-build-status", EnvVar: "CI_PREV_BUILD_STATUS", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Status }},
+	{Name: "prev-build-event", EnvVar: "CI_PREV_BUILD_EVENT", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Event }},
+	{Name: "prev-build-link", EnvVar: "CI_PREV_BUILD_LINK", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Link }},
+	{Name: "prev-commit-sha", EnvVar: "CI_PREV_COMMIT_SHA", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Commit.Sha }},
+	{Name: "prev-commit-ref", EnvVar: "CI_PREV_COMMIT_REF", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Commit.Ref }},
+	{Name: "prev-commit-refspec", EnvVar: "CI_PREV_COMMIT_REFSPEC", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Commit.Refspec }},
+	{Name: "prev-commit-branch", EnvVar: "CI_PREV_COMMIT_BRANCH", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Commit.Branch }},
+	{Name: "prev-commit-message", EnvVar: "CI_PREV_COMMIT_MESSAGE", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Commit.Message }},
+	{Name: "prev-commit-author-name", EnvVar: "CI_PREV_COMMIT_AUTHOR_NAME", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Commit.Author.Name }},
+	{Name: "prev-commit-author-avatar", EnvVar: "CI_PREV_COMMIT_AUTHOR_AVATAR", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Commit.Author.Avatar }},
+	{Name: "prev-commit-author-email", EnvVar: "CI_PREV_COMMIT_AUTHOR_EMAIL", value: func(m *frontend.Metadata) interface{} { return &m.Prev.Commit.Author.Email }},
+
+	{Name: "job-number", EnvVar: "CI_JOB_NUMBER", value: func(m *frontend.Metadata) interface{} { return &m.Job.Number }},
+}
+
+// Layer returns the base metadata with the values, keyed by field name,
+// layered over it. Fields that are empty after layering are set to
+// their default.
+func Layer(base frontend.Metadata, values map[string]string) (frontend.Metadata, error) {
+	m := base
+	for _, field := range Fields {
+		value, ok := values[field.Name]
+		if !ok {
+			if field.Default == "" || !field.empty(&m) {
+				continue
+			}
+			value = field.Default
+		}
+		if err := field.set(&m, value); err != nil {
+			return base, fmt.Errorf("invalid %s %q: %s", field.Name, value, err)
+		}
+	}
+	return m, nil
+}
+
+// Kind returns the kind of the field, which is a string, a bool, an int
+// or an int64.
+func (f *Field) Kind() reflect.Kind {
+	return reflect.TypeOf(f.value(&frontend.Metadata{})).Elem().Kind()
+}
+
+// set parses the value into the field of the metadata. An empty value
+// sets the zero value of the field.
+func (f *Field) set(m *frontend.Metadata, value string) error {
+	var err error
+	switch field := f.value(m).(type) {
+	case *string:
+		*field = value
+	case *bool:
+		*field = false
+		if value != "" {
+			*field, err = strconv.ParseBool(value)
+		}
+	case *int:
+		*field = 0
+		if value != "" {
+			*field, err = strconv.Atoi(value)
+		}
+	case *int64:
+		*field = 0
+		if value != "" {
+			*field, err = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	return err
+}
+
+// empty returns true if the field of the metadata has the zero value.
+func (f *Field) empty(m *frontend.Metadata) bool {
+	switch field := f.value(m).(type) {
+	case *string:
+		return *field == ""
+	case *bool:
+		return !*field
+	case *int:
+		return *field == 0
+	case *int64:
+		return *field == 0
+	}
+	return true
+}
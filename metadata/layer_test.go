@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+func TestLayer(t *testing.T) {
+	base := frontend.Metadata{}
+	base.Sys.Name = "bitbucket"
+	base.Repo.Name = "octocat/hello-world"
+	base.Repo.Private = true
+	base.Curr.Number = 42
+	base.Curr.Commit.Branch = "develop"
+
+	tests := []struct {
+		name   string
+		base   frontend.Metadata
+		values map[string]string
+		check  func(m frontend.Metadata) bool
+	}{
+		{
+			name:   "defaults fill empty fields",
+			values: map[string]string{},
+			check: func(m frontend.Metadata) bool {
+				return m.Sys.Name == DefaultSystemName && m.Sys.Link == DefaultSystemLink && m.Sys.Arch == DefaultSystemArch
+			},
+		},
+		{
+			name:   "defaults do not override the base",
+			base:   base,
+			values: map[string]string{},
+			check: func(m frontend.Metadata) bool {
+				return m.Sys.Name == "bitbucket" && m.Sys.Arch == DefaultSystemArch
+			},
+		},
+		{
+			name:   "absent values keep the base",
+			base:   base,
+			values: map[string]string{"commit-branch": "master"},
+			check: func(m frontend.Metadata) bool {
+				return m.Repo.Name == "octocat/hello-world" && m.Repo.Private && m.Curr.Number == 42 && m.Curr.Commit.Branch == "master"
+			},
+		},
+		{
+			name:   "values override the base",
+			base:   base,
+			values: map[string]string{"system-name": "pipec", "repo-private": "false", "build-number": "7"},
+			check: func(m frontend.Metadata) bool {
+				return m.Sys.Name == "pipec" && !m.Repo.Private && m.Curr.Number == 7
+			},
+		},
+		{
+			name:   "empty values clear the base",
+			base:   base,
+			values: map[string]string{"repo-name": "", "build-number": ""},
+			check: func(m frontend.Metadata) bool {
+				return m.Repo.Name == "" && m.Curr.Number == 0
+			},
+		},
+		{
+			name:   "empty values are not replaced by defaults",
+			base:   base,
+			values: map[string]string{"system-name": ""},
+			check: func(m frontend.Metadata) bool {
+				return m.Sys.Name == ""
+			},
+		},
+	}
+	for _, test := range tests {
+		m, err := Layer(test.base, test.values)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !test.check(m) {
+			t.Errorf("%s: unexpected metadata %+v", test.name, m)
+		}
+	}
+}
+
+func TestLayerInvalid(t *testing.T) {
+	for name, value := range map[string]string{
+		"build-number": "one",
+		"repo-private": "maybe",
+		"job-number":   "1.5",
+	} {
+		_, err := Layer(frontend.Metadata{}, map[string]string{name: value})
+		if err == nil {
+			t.Errorf("Expect error for %s %q", name, value)
+		}
+	}
+}
+
+func TestFieldKind(t *testing.T) {
+	kinds := map[string]reflect.Kind{}
+	for _, field := range Fields {
+		kinds[field.Name] = field.Kind()
+	}
+	tests := map[string]reflect.Kind{
+		"repo-name":     reflect.String,
+		"repo-private":  reflect.Bool,
+		"build-number":  reflect.Int,
+		"build-created": reflect.Int64,
+	}
+	for name, want := range tests {
+		if got := kinds[name]; want != got {
+			t.Errorf("Wanted %s kind %s, got %s", name, want, got)
+		}
+	}
+}
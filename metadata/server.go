@@ -0,0 +1,208 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+// Bitbucket Server and Data Center webhook event keys, sent in the
+// X-Event-Key header.
+const (
+	ServerEventPush             = "repo:refs_changed"
+	ServerEventPullRequestOpen  = "pr:opened"
+	ServerEventPullRequestPatch = "pr:from_ref_updated"
+)
+
+// serverTimeFormat is the format of the webhook date, which unlike RFC
+// 3339 has no colon in the zone offset.
+const serverTimeFormat = "2006-01-02T15:04:05-0700"
+
+type (
+	serverLink struct {
+		Href string `json:"href"`
+		Name string `json:"name"`
+	}
+
+	serverUser struct {
+		DisplayName  string `json:"displayName"`
+		EmailAddress string `json:"emailAddress"`
+	}
+
+	serverRepository struct {
+		Slug    string `json:"slug"`
+		Public  bool   `json:"public"`
+		Project struct {
+			Key string `json:"key"`
+		} `json:"project"`
+		Links struct {
+			Clone []serverLink `json:"clone"`
+			Self  []serverLink `json:"self"`
+		} `json:"links"`
+	}
+
+	serverRef struct {
+		ID           string           `json:"id"`
+		DisplayID    string           `json:"displayId"`
+		LatestCommit string           `json:"latestCommit"`
+		Repository   serverRepository `json:"repository"`
+	}
+
+	serverPushHook struct {
+		Date       string           `json:"date"`
+		Actor      serverUser       `json:"actor"`
+		Repository serverRepository `json:"repository"`
+		Changes    []struct {
+			Ref struct {
+				ID        string `json:"id"`
+				DisplayID string `json:"displayId"`
+				Type      string `json:"type"`
+			} `json:"ref"`
+			FromHash string `json:"fromHash"`
+			ToHash   string `json:"toHash"`
+			Type     string `json:"type"`
+		} `json:"changes"`
+	}
+
+	serverPullRequestHook struct {
+		Date             string `json:"date"`
+		PreviousFromHash string `json:"previousFromHash"`
+		PullRequest      struct {
+			ID     int64     `json:"id"`
+			Title  string    `json:"title"`
+			From   serverRef `json:"fromRef"`
+			To     serverRef `json:"toRef"`
+			Author struct {
+				User serverUser `json:"user"`
+			} `json:"author"`
+			Links struct {
+				Self []serverLink `json:"self"`
+			} `json:"links"`
+		} `json:"pullRequest"`
+	}
+)
+
+// FromServerWebhook returns the metadata of the builds triggered by the
+// Bitbucket Server or Data Center webhook payload with the event key. A
+// push returns the metadata of every branch and tag change in the push,
+// excluding deleted branches and tags. A pull request returns the
+// metadata of the source commit, fetched through the pull request ref
+// of the destination repository so that pull requests from forks are
+// built without access to the fork.
+func FromServerWebhook(eventKey string, payload []byte) ([]frontend.Metadata, error) {
+	switch eventKey {
+	case ServerEventPush:
+		hook := new(serverPushHook)
+		if err := json.Unmarshal(payload, hook); err != nil {
+			return nil, fmt.Errorf("invalid %s payload: %s", eventKey, err)
+		}
+		return serverPush(hook), nil
+	case ServerEventPullRequestOpen, ServerEventPullRequestPatch:
+		hook := new(serverPullRequestHook)
+		if err := json.Unmarshal(payload, hook); err != nil {
+			return nil, fmt.Errorf("invalid %s payload: %s", eventKey, err)
+		}
+		return []frontend.Metadata{serverPullRequest(hook)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported event key %q", eventKey)
+	}
+}
+
+// serverPush returns the metadata of the changes of the push. The
+// payload does not include the commit message, and the author is the
+// user who pushed the changes.
+func serverPush(hook *serverPushHook) []frontend.Metadata {
+	var out []frontend.Metadata
+	for _, change := range hook.Changes {
+		if change.Type == "DELETE" {
+			continue
+		}
+		m := frontend.Metadata{Repo: serverRepo(hook.Repository)}
+		m.Curr.Created = serverTime(hook.Date)
+		m.Curr.Commit.Sha = change.ToHash
+		m.Curr.Commit.Ref = change.Ref.ID
+		m.Curr.Commit.Author.Name = hook.Actor.DisplayName
+		m.Curr.Commit.Author.Email = hook.Actor.EmailAddress
+		if change.Type != "ADD" {
+			m.Prev.Commit.Sha = change.FromHash
+		}
+
+		switch change.Ref.Type {
+		case "TAG":
+			m.Curr.Event = frontend.EventTag
+		default:
+			m.Curr.Event = frontend.EventPush
+			m.Curr.Commit.Branch = change.Ref.DisplayID
+		}
+		if m.Repo.Link != "" {
+			m.Curr.Link = serverBase(m.Repo.Link) + "/commits/" + change.ToHash
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// serverPullRequest returns the metadata of the pull request.
+func serverPullRequest(hook *serverPullRequestHook) frontend.Metadata {
+	pr := hook.PullRequest
+	m := frontend.Metadata{Repo: serverRepo(pr.To.Repository)}
+	m.Curr.Event = frontend.EventPull
+	m.Curr.Created = serverTime(hook.Date)
+	m.Curr.Target = pr.To.DisplayID
+	m.Curr.Commit.Sha = pr.From.LatestCommit
+	m.Curr.Commit.Branch = pr.From.DisplayID
+	m.Curr.Commit.Message = pr.Title
+	m.Curr.Commit.Author.Name = pr.Author.User.DisplayName
+	m.Curr.Commit.Author.Email = pr.Author.User.EmailAddress
+	m.Prev.Commit.Sha = hook.PreviousFromHash
+	if len(pr.Links.Self) != 0 {
+		m.Curr.Link = pr.Links.Self[0].Href
+	}
+
+	// the source commit is fetched from the pull request ref, which the
+	// server maintains in the destination repository even if the source
+	// branch is in a fork. The /from ref is used instead of the /merge
+	// ref because the server only updates the merge ref lazily and
+	// removes it when the pull request has conflicts, and the clone step
+	// merges the source into the destination itself. As for Bitbucket
+	// Cloud, the refspec is source:destination with branch names.
+	m.Curr.Commit.Ref = fmt.Sprintf("refs/pull-requests/%d/from", pr.ID)
+	m.Curr.Commit.Refspec = pr.From.DisplayID + ":" + pr.To.DisplayID
+	return m
+}
+
+// serverRepo returns the repository metadata. The full name is the
+// project key and the repository slug.
+func serverRepo(repo serverRepository) frontend.Repo {
+	out := frontend.Repo{
+		Name:    repo.Project.Key + "/" + repo.Slug,
+		Private: !repo.Public,
+	}
+	if len(repo.Links.Self) != 0 {
+		out.Link = repo.Links.Self[0].Href
+	}
+	for _, link := range repo.Links.Clone {
+		if out.Remote == "" || link.Name == "http" {
+			out.Remote = link.Href
+		}
+	}
+	return out
+}
+
+// serverBase returns the repository link without the browse suffix.
+func serverBase(link string) string {
+	return strings.TrimSuffix(link, "/browse")
+}
+
+// serverTime returns the unix time of the webhook date, or zero if the
+// date is invalid.
+func serverTime(date string) int64 {
+	t, err := time.Parse(serverTimeFormat, date)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
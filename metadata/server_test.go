@@ -0,0 +1,126 @@
+package metadata
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+func TestServerPush(t *testing.T) {
+	payload, err := ioutil.ReadFile("testdata/server/repo_refs_changed.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := FromServerWebhook(ServerEventPush, payload)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the deleted branch is skipped.
+	if want, got := 2, len(out); want != got {
+		t.Errorf("Wanted %d builds, got %d", want, got)
+		return
+	}
+
+	branch, tag := out[0], out[1]
+	for _, test := range []struct{ name, want, got string }{
+		{"event", frontend.EventPush, branch.Curr.Event},
+		{"ref", "refs/heads/master", branch.Curr.Commit.Ref},
+		{"branch", "master", branch.Curr.Commit.Branch},
+		{"sha", "178864a7d521b6f5e720b386b2c2b0ef8563e0dc", branch.Curr.Commit.Sha},
+		{"prev sha", "ecddabb624f6f5ba43816f5926e580a5f680a932", branch.Prev.Commit.Sha},
+		{"author", "Octo Cat", branch.Curr.Commit.Author.Name},
+		{"email", "octocat@example.com", branch.Curr.Commit.Author.Email},
+		{"link", "https://git.example.com/projects/PROJ/repos/hello-world/commits/178864a7d521b6f5e720b386b2c2b0ef8563e0dc", branch.Curr.Link},
+		{"repo", "PROJ/hello-world", branch.Repo.Name},
+		{"remote", "https://git.example.com/scm/proj/hello-world.git", branch.Repo.Remote},
+		{"tag event", frontend.EventTag, tag.Curr.Event},
+		{"tag ref", "refs/tags/v1.0.0", tag.Curr.Commit.Ref},
+		{"tag branch", "", tag.Curr.Commit.Branch},
+		{"tag prev sha", "", tag.Prev.Commit.Sha},
+	} {
+		if test.want != test.got {
+			t.Errorf("Wanted %s %q, got %q", test.name, test.want, test.got)
+		}
+	}
+	if !branch.Repo.Private {
+		t.Errorf("Expect private repository")
+	}
+	if want, got := int64(1505779091), branch.Curr.Created; want != got {
+		t.Errorf("Wanted created %d, got %d", want, got)
+	}
+}
+
+func TestServerPullRequest(t *testing.T) {
+	tests := []struct {
+		file, event                                string
+		sha, ref, refspec, branch, target, message string
+		prev                                       string
+	}{
+		{
+			file:    "testdata/server/pr_opened.json",
+			event:   ServerEventPullRequestOpen,
+			sha:     "ef8755f06ee4b28c96a847a95cb8ec8ed6ddd1ca",
+			ref:     "refs/pull-requests/1/from",
+			refspec: "feature/greeting:master",
+			branch:  "feature/greeting",
+			target:  "master",
+			message: "Add the greeting",
+		},
+		{
+			file:    "testdata/server/pr_from_ref_updated.json",
+			event:   ServerEventPullRequestPatch,
+			sha:     "a00945762949b7787df6ab6a29b3ea3c2c4e9ba1",
+			ref:     "refs/pull-requests/2/from",
+			refspec: "bugfix/build:develop",
+			branch:  "bugfix/build",
+			target:  "develop",
+			message: "Fix the build",
+			prev:    "ef8755f06ee4b28c96a847a95cb8ec8ed6ddd1ca",
+		},
+	}
+	for _, test := range tests {
+		payload, err := ioutil.ReadFile(test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := FromServerWebhook(test.event, payload)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if want, got := 1, len(out); want != got {
+			t.Errorf("Wanted %d builds, got %d", want, got)
+			continue
+		}
+		m := out[0]
+		for _, field := range []struct{ name, want, got string }{
+			{"event", frontend.EventPull, m.Curr.Event},
+			{"sha", test.sha, m.Curr.Commit.Sha},
+			{"ref", test.ref, m.Curr.Commit.Ref},
+			{"refspec", test.refspec, m.Curr.Commit.Refspec},
+			{"branch", test.branch, m.Curr.Commit.Branch},
+			{"target", test.target, m.Curr.Target},
+			{"message", test.message, m.Curr.Commit.Message},
+			{"prev sha", test.prev, m.Prev.Commit.Sha},
+			// pull requests from forks are fetched from the destination.
+			{"remote", "https://git.example.com/scm/proj/hello-world.git", m.Repo.Remote},
+			{"repo", "PROJ/hello-world", m.Repo.Name},
+		} {
+			if field.want != field.got {
+				t.Errorf("%s: wanted %s %q, got %q", test.file, field.name, field.want, field.got)
+			}
+		}
+	}
+}
+
+func TestServerUnsupported(t *testing.T) {
+	if _, err := FromServerWebhook("repo:forked", []byte("{}")); err == nil {
+		t.Errorf("Expect error for unsupported event key")
+	}
+	if _, err := FromServerWebhook(ServerEventPush, []byte("{")); err == nil {
+		t.Errorf("Expect error for invalid payload")
+	}
+}
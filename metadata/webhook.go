@@ -0,0 +1,21 @@
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+// FromWebhook returns the metadata of the builds triggered by the
+// Bitbucket Cloud or Bitbucket Server webhook payload, depending on the
+// event key. The event keys of the two products do not overlap.
+func FromWebhook(eventKey string, payload []byte) ([]frontend.Metadata, error) {
+	switch eventKey {
+	case CloudEventPush, CloudEventPullRequestOpen, CloudEventPullRequestPatch:
+		return FromCloudWebhook(eventKey, payload)
+	case ServerEventPush, ServerEventPullRequestOpen, ServerEventPullRequestPatch:
+		return FromServerWebhook(eventKey, payload)
+	default:
+		return nil, fmt.Errorf("unsupported event key %q", eventKey)
+	}
+}
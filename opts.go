@@ -1,10 +1,18 @@
 package bitbucket
 
-import "github.com/cncd/pipeline/pipeline/frontend"
+import (
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
 
 // Option configures a compiler option.
 type Option func(*Compiler)
 
+// Transform rewrites the compiled pipeline configuration, for example to
+// add labels or steps required by an organization. It receives the yaml
+// configuration and the metadata the pipeline was compiled from.
+type Transform func(*backend.Config, *Config, frontend.Metadata) error
+
 // WithVolumes configutes the compiler with default volumes that
 // are mounted to each container in the pipeline.
 func WithVolumes(volumes ...string) Option {
@@ -78,6 +86,55 @@ func WithEnviron(env map[string]string) Option {
 	}
 }
 
+// WithDeployment configures the compiler with environment variables
+// for the named deployment environment. The variables are added to
+// every step that deploys to the environment, including the steps of
+// a stage that declares the deployment.
+func WithDeployment(name string, env map[string]string) Option {
+	return func(compiler *Compiler) {
+		if compiler.deployments[name] == nil {
+			compiler.deployments[name] = map[string]string{}
+		}
+		for k, v := range env {
+			compiler.deployments[name][k] = v
+		}
+	}
+}
+
+// WithOverrides configures the compiler with environment variables
+// that take precedence over the variables of every other option,
+// including the deployment variables, such as the variables passed
+// on the command line.
+func WithOverrides(env map[string]string) Option {
+	return func(compiler *Compiler) {
+		for k, v := range env {
+			compiler.overrides[k] = v
+		}
+	}
+}
+
+// WithManual configures the compiler to include steps that must be
+// triggered manually. By default the compiled pipeline ends before
+// the first manual step or stage, where Bitbucket pauses the pipeline.
+func WithManual(manual bool) Option {
+	return func(compiler *Compiler) {
+		compiler.manual = manual
+	}
+}
+
+// WithSecrets configures the compiler with secret environment variables.
+// Secrets are kept out of the environment of the compiled containers
+// and declared by name in the secrets section of the compiled pipeline,
+// without their values. The runtime injects the secrets into every
+// container and masks their values in the build logs.
+func WithSecrets(secrets map[string]string) Option {
+	return func(compiler *Compiler) {
+		for k, v := range secrets {
+			compiler.secrets[k] = v
+		}
+	}
+}
+
 // WithLocal configures the compiler with the local flag. The local
 // flag indicates the pipeline execution is running in a local development
 // environment with a mounted local working directory.
@@ -87,6 +144,36 @@ func WithLocal(local bool) Option {
 	}
 }
 
+// WithShell configures the compiler with the shell used to execute the
+// build script, for example /bin/sh. By default the script is executed
+// with bash when the image provides it, as in Bitbucket, and with sh
+// otherwise.
+func WithShell(shell string) Option {
+	return func(compiler *Compiler) {
+		compiler.shell = shell
+	}
+}
+
+// WithPipefail configures the compiler to enable the pipefail option
+// of the shell, which fails a command pipeline if any command in the
+// pipeline fails. The option is ignored by shells that do not support
+// it.
+func WithPipefail(pipefail bool) Option {
+	return func(compiler *Compiler) {
+		compiler.pipefail = pipefail
+	}
+}
+
+// WithTraceMarkers configures the compiler to write machine-parseable
+// markers to the build logs before and after each command, with the
+// index of the command, the exit status and the time. The markers are
+// parsed with ParseTrace.
+func WithTraceMarkers(markers bool) Option {
+	return func(compiler *Compiler) {
+		compiler.markers = markers
+	}
+}
+
 // WithProxy configures the compiler with HTTP_PROXY, HTTPS_PROXY,
 // and NO_PROXY environment variables added by default to every
 // container in the pipeline.
@@ -102,3 +189,12 @@ func WithProxy(http, https, none string) Option {
 		},
 	)
 }
+
+// WithTransform configures the compiler with a transform applied to the
+// compiled configuration. Transforms are applied in the order they are
+// configured, and an error returned by a transform fails the compile.
+func WithTransform(transform Transform) Option {
+	return func(compiler *Compiler) {
+		compiler.transforms = append(compiler.transforms, transform)
+	}
+}
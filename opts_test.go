@@ -1,8 +1,11 @@
 package bitbucket
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/cncd/pipeline/pipeline/backend"
 	"github.com/cncd/pipeline/pipeline/frontend"
 )
 
@@ -130,3 +133,175 @@ func TestWithEnviron(t *testing.T) {
 		t.Errorf("WithEnviron should set SHOW")
 	}
 }
+
+func TestWithDeployment(t *testing.T) {
+	compiler := NewCompiler(
+		WithDeployment("staging", map[string]string{
+			"API_URL": "https://staging.example.com",
+		}),
+	)
+	if compiler.deployments["staging"]["API_URL"] != "https://staging.example.com" {
+		t.Errorf("WithDeployment should set the staging variables")
+	}
+	if _, ok := compiler.env["API_URL"]; ok {
+		t.Errorf("WithDeployment should not set global variables")
+	}
+}
+
+func TestWithOverrides(t *testing.T) {
+	config, err := ParseString(`
+pipelines:
+  default:
+    - step:
+        deployment: staging
+        script: [ make deploy ]
+`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	compiled, err := NewCompiler(
+		WithLocal(true),
+		WithEnviron(map[string]string{"API_URL": "workspace", "REGION": "us"}),
+		WithEnviron(map[string]string{"API_URL": "repository"}),
+		WithDeployment("staging", map[string]string{"API_URL": "staging", "TOKEN": "abc"}),
+		WithOverrides(map[string]string{"API_URL": "override"}),
+	).Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	env := compiled.Stages[0].Steps[0].Environment
+	for name, want := range map[string]string{
+		"API_URL": "override",
+		"REGION":  "us",
+		"TOKEN":   "abc",
+	} {
+		if got := env[name]; got != want {
+			t.Errorf("Wanted %s %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestWithManual(t *testing.T) {
+	if NewCompiler(WithManual(true)).manual == false {
+		t.Errorf("WithManual true must enable the manual flag")
+	}
+	if NewCompiler(WithManual(false)).manual == true {
+		t.Errorf("WithManual false must disable the manual flag")
+	}
+}
+
+func TestWithShell(t *testing.T) {
+	if NewCompiler(WithShell("/bin/sh")).shell != "/bin/sh" {
+		t.Errorf("WithShell must set the shell")
+	}
+}
+
+func TestWithPipefail(t *testing.T) {
+	if NewCompiler(WithPipefail(true)).pipefail == false {
+		t.Errorf("WithPipefail true must enable the pipefail flag")
+	}
+	if NewCompiler(WithPipefail(false)).pipefail == true {
+		t.Errorf("WithPipefail false must disable the pipefail flag")
+	}
+}
+
+func TestWithSecrets(t *testing.T) {
+	compiler := NewCompiler(
+		WithSecrets(map[string]string{
+			"DOCKER_PASSWORD": "password",
+		}),
+	)
+	if compiler.secrets["DOCKER_PASSWORD"] != "password" {
+		t.Errorf("WithSecrets should set DOCKER_PASSWORD")
+	}
+}
+
+func TestWithTraceMarkers(t *testing.T) {
+	if NewCompiler(WithTraceMarkers(true)).markers == false {
+		t.Errorf("WithTraceMarkers true must enable trace markers")
+	}
+}
+
+func TestWithTransform(t *testing.T) {
+	config, err := ParseString(`
+image: golang:1.9
+pipelines:
+  default:
+    - step:
+        script: [ go test ]
+  branches:
+    master:
+      - step:
+          script: [ go build ]
+`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	metadata := frontend.Metadata{Repo: frontend.Repo{Name: "octocat/hello-world"}}
+	var order []string
+	label := func(spec *backend.Config, conf *Config, m frontend.Metadata) error {
+		order = append(order, "label")
+		for _, stage := range spec.Stages {
+			for _, step := range stage.Steps {
+				step.Labels = map[string]string{"repo": m.Repo.Name, "image": conf.Image}
+			}
+		}
+		return nil
+	}
+	audit := func(spec *backend.Config, conf *Config, m frontend.Metadata) error {
+		order = append(order, "audit")
+		spec.Stages = append(spec.Stages, &backend.Stage{
+			Name:  "audit",
+			Steps: []*backend.Step{{Name: "audit", Image: "audit:latest"}},
+		})
+		return nil
+	}
+
+	compiled, err := NewCompiler(
+		WithLocal(true),
+		WithMetadata(metadata),
+		WithTransform(label),
+		WithTransform(audit),
+	).Compile(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := "label audit", strings.Join(order, " "); want != got {
+		t.Errorf("Wanted transforms applied in order %q, got %q", want, got)
+	}
+	if want, got := 2, len(compiled.Stages); want != got {
+		t.Errorf("Wanted %d stages including the audit stage, got %d", want, got)
+		return
+	}
+	labels := compiled.Stages[0].Steps[0].Labels
+	if labels["repo"] != "octocat/hello-world" || labels["image"] != "golang:1.9" {
+		t.Errorf("Expect transforms receive the configuration and metadata, got labels %v", labels)
+	}
+	if compiled.Stages[1].Steps[0].Labels != nil {
+		t.Errorf("Expect the audit step is added after the labels")
+	}
+
+	// an error fails the compile and stops the following transforms.
+	order = nil
+	reject := func(*backend.Config, *Config, frontend.Metadata) error {
+		order = append(order, "reject")
+		return errors.New("image not allowed")
+	}
+	compiler := NewCompiler(WithLocal(true), WithTransform(reject), WithTransform(audit))
+	if _, err := compiler.Compile(config); err == nil || err.Error() != "image not allowed" {
+		t.Errorf("Expect the transform error, got %v", err)
+	}
+	if _, err := compiler.CompileAll(config); err == nil || !strings.Contains(err.Error(), "image not allowed") {
+		t.Errorf("Expect the transform error from CompileAll, got %v", err)
+	}
+	if want, got := "reject reject", strings.Join(order, " "); want != got {
+		t.Errorf("Wanted transforms %q, got %q", want, got)
+	}
+}
@@ -8,39 +8,66 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// ParseOption configures a parse option.
+type ParseOption func(*parser)
+
+// parser holds the parse options.
+type parser struct {
+	resolver ConfigResolver
+}
+
+// WithResolver configures the parser with the resolver used to
+// fetch the configuration of other repositories when importing
+// shared pipelines.
+func WithResolver(resolver ConfigResolver) ParseOption {
+	return func(p *parser) {
+		p.resolver = resolver
+	}
+}
+
 // Parse parses the configuration from bytes b.
-func Parse(r io.Reader) (*Config, error) {
+func Parse(r io.Reader, opts ...ParseOption) (*Config, error) {
 	out, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	return ParseBytes(out)
+	return ParseBytes(out, opts...)
 }
 
 // ParseBytes parses the configuration from bytes b.
-func ParseBytes(b []byte) (*Config, error) {
+func ParseBytes(b []byte, opts ...ParseOption) (*Config, error) {
+	p := new(parser)
+	for _, opt := range opts {
+		opt(p)
+	}
+
 	out := new(Config)
 	err := yaml.Unmarshal(b, out)
 	if err != nil {
 		return nil, err
 	}
 
+	err = resolveImports(out, p.resolver)
+	if err != nil {
+		return nil, err
+	}
 	return out, nil
 }
 
 // ParseString parses the configuration from string s.
-func ParseString(s string) (*Config, error) {
+func ParseString(s string, opts ...ParseOption) (*Config, error) {
 	return ParseBytes(
 		[]byte(s),
+		opts...,
 	)
 }
 
 // ParseFile parses the configuration from path p.
-func ParseFile(p string) (*Config, error) {
+func ParseFile(p string, opts ...ParseOption) (*Config, error) {
 	f, err := os.Open(p)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	return Parse(f)
+	return Parse(f, opts...)
 }
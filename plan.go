@@ -0,0 +1,146 @@
+package bitbucket
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+type (
+	// Plan explains which pipeline is selected for the build metadata
+	// and what the compiled pipeline runs.
+	Plan struct {
+		Ref        string       `json:"ref"`
+		Branch     string       `json:"branch"`
+		Selector   Selector     `json:"selector"`
+		Candidates []*Candidate `json:"candidates"`
+		Workspace  string       `json:"workspace"`
+		Volumes    []string     `json:"volumes"`
+		Clone      *PlanClone   `json:"clone,omitempty"`
+		Steps      []*PlanStep  `json:"steps"`
+	}
+
+	// PlanClone describes the clone step of the pipeline. The clone
+	// step is omitted from local builds.
+	PlanClone struct {
+		Image string `json:"image"`
+		Depth int    `json:"depth"`
+	}
+
+	// PlanStep describes a step of the selected pipeline.
+	PlanStep struct {
+		Name       string `json:"name"`
+		Alias      string `json:"alias"`
+		Image      string `json:"image"`
+		Platform   string `json:"platform"`
+		Deployment string `json:"deployment,omitempty"`
+
+		// Commands are the script of the configured step, or the
+		// command of a step added by a transform.
+		Commands []string `json:"commands"`
+
+		// Manual is true if the step must be triggered manually.
+		Manual bool `json:"manual,omitempty"`
+
+		// Skipped is true if the step is not part of the compiled
+		// pipeline because it follows a manual trigger.
+		Skipped bool `json:"skipped,omitempty"`
+
+		// Script is the decoded build script of the step. It is
+		// empty if the step is skipped.
+		Script string `json:"script,omitempty"`
+	}
+)
+
+// Plan compiles the YAML configuration and explains the selection of
+// the pipeline for the metadata and the steps the pipeline runs. The
+// transforms of the compiler are applied, so the images, platforms and
+// scripts are those of the compiled pipeline. Steps added by a transform
+// follow the steps of the configuration.
+func (c *Compiler) Plan(conf *Config) (*Plan, error) {
+	ref, branch := c.meta.Curr.Commit.Ref, c.meta.Curr.Commit.Branch
+	selector, candidates := conf.Match(ref, branch)
+	section, _ := conf.Lookup(selector)
+	spec, err := c.transform(c.compile(conf, section), conf)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Ref:        ref,
+		Branch:     branch,
+		Selector:   selector,
+		Candidates: candidates,
+		Workspace:  path.Join(c.base, c.path),
+	}
+
+	compiled := map[string]*backend.Step{}
+	var added []*backend.Step
+	for _, stage := range spec.Stages {
+		for _, step := range stage.Steps {
+			compiled[step.Alias] = step
+			plan.Volumes = step.Volumes
+			if step.Alias != "clone" && !isConfiguredStep(section, step.Alias) {
+				added = append(added, step)
+			}
+		}
+	}
+	if step, ok := compiled["clone"]; ok {
+		plan.Clone = &PlanClone{
+			Image: step.Image,
+			Depth: conf.Clone.Depth,
+		}
+	}
+
+	for i, step := range section.Steps {
+		planned := &PlanStep{
+			Name:       step.Name,
+			Alias:      fmt.Sprintf("step_%d", i),
+			Image:      stepImage(conf, step),
+			Platform:   c.platformFor(step),
+			Deployment: step.Target(),
+			Commands:   step.Script,
+			Manual:     section.Gated(i),
+		}
+		if step, ok := compiled[planned.Alias]; ok {
+			planned.Image = step.Image
+			if platform, ok := step.Labels["platform"]; ok {
+				planned.Platform = platform
+			}
+			planned.Script = decodeScript(step)
+		} else {
+			planned.Skipped = true
+		}
+		plan.Steps = append(plan.Steps, planned)
+	}
+	for _, step := range added {
+		plan.Steps = append(plan.Steps, &PlanStep{
+			Name:     step.Name,
+			Alias:    step.Alias,
+			Image:    step.Image,
+			Platform: step.Labels["platform"],
+			Commands: step.Command,
+			Script:   decodeScript(step),
+		})
+	}
+	return plan, nil
+}
+
+// isConfiguredStep returns true if the alias is the alias of a step of
+// the configured pipeline.
+func isConfiguredStep(section Stage, alias string) bool {
+	for i := range section.Steps {
+		if alias == fmt.Sprintf("step_%d", i) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeScript returns the decoded build script of the compiled step.
+func decodeScript(step *backend.Step) string {
+	script, _ := base64.StdEncoding.DecodeString(step.Environment["CI_SCRIPT"])
+	return string(script)
+}
@@ -0,0 +1,146 @@
+package bitbucket
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+func TestPlan(t *testing.T) {
+	config, err := ParseString(planYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	metadata := frontend.Metadata{}
+	metadata.Curr.Commit.Ref = "refs/heads/master"
+	metadata.Curr.Commit.Branch = "master"
+
+	plan, err := NewCompiler(
+		WithPrefix("test"),
+		WithVolumes("/tmp/cache:/cache"),
+		WithMetadata(metadata),
+	).Plan(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if want, got := "branches/master", plan.Selector.String(); want != got {
+		t.Errorf("Wanted selector %s, got %s", want, got)
+	}
+	if want, got := 1, len(plan.Candidates); want != got {
+		t.Errorf("Wanted %d candidates, got %d", want, got)
+	}
+	if plan.Clone == nil {
+		t.Errorf("Expect clone step in the plan")
+	} else if want, got := "plugins/git:latest", plan.Clone.Image; want != got {
+		t.Errorf("Wanted clone image %s, got %s", want, got)
+	}
+	if want, got := []string{"test_workspace:/workspace", "/tmp/cache:/cache"}, plan.Volumes; strings.Join(want, ",") != strings.Join(got, ",") {
+		t.Errorf("Wanted volumes %v, got %v", want, got)
+	}
+	if want, got := 2, len(plan.Steps); want != got {
+		t.Errorf("Wanted %d steps, got %d", want, got)
+		return
+	}
+
+	build, deploy := plan.Steps[0], plan.Steps[1]
+	if want, got := "golang:1.8", build.Image; want != got {
+		t.Errorf("Wanted image %s, got %s", want, got)
+	}
+	if !strings.Contains(build.Script, "go test") {
+		t.Errorf("Expect decoded script of the build step")
+	}
+	if want, got := "node:latest", deploy.Image; want != got {
+		t.Errorf("Wanted image %s, got %s", want, got)
+	}
+	if !deploy.Manual || !deploy.Skipped {
+		t.Errorf("Expect manual deploy step is skipped")
+	}
+	if deploy.Script != "" {
+		t.Errorf("Expect no script for the skipped step")
+	}
+	if want, got := "production", deploy.Deployment; want != got {
+		t.Errorf("Wanted deployment %s, got %s", want, got)
+	}
+}
+
+func TestPlanTransform(t *testing.T) {
+	config, err := ParseString(planYaml)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the transform mirrors the images and appends an audit step.
+	mirror := func(spec *backend.Config, conf *Config, m frontend.Metadata) error {
+		for _, stage := range spec.Stages {
+			for _, step := range stage.Steps {
+				step.Image = "mirror.example.com/" + step.Image
+			}
+		}
+		spec.Stages = append(spec.Stages, &backend.Stage{
+			Name:  "test_audit",
+			Alias: "audit",
+			Steps: []*backend.Step{{
+				Name:    "test_audit",
+				Alias:   "audit",
+				Image:   "audit:latest",
+				Command: []string{"audit"},
+			}},
+		})
+		return nil
+	}
+	plan, err := NewCompiler(WithPrefix("test"), WithTransform(mirror)).Plan(config)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := "mirror.example.com/plugins/git:latest", plan.Clone.Image; want != got {
+		t.Errorf("Wanted clone image %s, got %s", want, got)
+	}
+	if want, got := 2, len(plan.Steps); want != got {
+		t.Errorf("Wanted %d steps, got %d", want, got)
+		return
+	}
+	if want, got := "mirror.example.com/node:latest", plan.Steps[0].Image; want != got {
+		t.Errorf("Wanted transformed image %s, got %s", want, got)
+	}
+	audit := plan.Steps[1]
+	if want, got := "audit", audit.Alias; want != got {
+		t.Errorf("Wanted added step %s, got %s", want, got)
+	}
+	if want, got := "audit", strings.Join(audit.Commands, " "); want != got {
+		t.Errorf("Wanted added step commands %q, got %q", want, got)
+	}
+
+	reject := func(*backend.Config, *Config, frontend.Metadata) error {
+		return errors.New("image not allowed")
+	}
+	if _, err := NewCompiler(WithTransform(reject)).Plan(config); err == nil || err.Error() != "image not allowed" {
+		t.Errorf("Expect the transform error, got %v", err)
+	}
+}
+
+var planYaml = `
+image: node
+
+pipelines:
+  default:
+    - step:
+        script: [ npm test ]
+  branches:
+    master:
+      - step:
+          image: golang:1.8
+          script: [ go test ]
+      - step:
+          deployment: production
+          trigger: manual
+          script: [ npm publish ]
+`
@@ -0,0 +1,69 @@
+package bitbucket
+
+import "github.com/cncd/pipeline/pipeline/frontend"
+
+// defaults of the compiler options of a request.
+const (
+	DefaultPrefix        = "pipeline"
+	DefaultWorkspaceBase = "/workspace"
+)
+
+// RequestOptions are the compiler options of a compile request received
+// by a service, such as the http server or the rpc service.
+type RequestOptions struct {
+	// Metadata is the build metadata the pipeline is compiled for.
+	Metadata frontend.Metadata
+
+	// Prefix is the prefix of the compiled names, DefaultPrefix if
+	// empty.
+	Prefix string
+
+	// WorkspaceBase and WorkspacePath are the workspace of the
+	// pipeline. A path without a base is relative to
+	// DefaultWorkspaceBase, as for the command line tool.
+	WorkspaceBase string
+	WorkspacePath string
+
+	// Volumes are the default volumes of every container.
+	Volumes []string
+
+	// NetrcUsername, NetrcPassword and NetrcMachine are the netrc
+	// credentials added to every container.
+	NetrcUsername string
+	NetrcPassword string
+	NetrcMachine  string
+
+	// Environ are the environment variables of every container.
+	Environ map[string]string
+
+	// Local and Manual configure the compiler for local builds and
+	// to include the steps that follow a manual trigger.
+	Local  bool
+	Manual bool
+}
+
+// Options returns the compiler options of the request, and the
+// transforms registered with RegisterTransform.
+func (r *RequestOptions) Options() []Option {
+	prefix := r.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	opts := []Option{
+		WithPrefix(prefix),
+		WithVolumes(r.Volumes...),
+		WithLocal(r.Local),
+		WithManual(r.Manual),
+		WithNetrc(r.NetrcUsername, r.NetrcPassword, r.NetrcMachine),
+		WithMetadata(r.Metadata),
+		WithEnviron(r.Environ),
+		WithRegisteredTransforms(),
+	}
+	if base, path := r.WorkspaceBase, r.WorkspacePath; base != "" || path != "" {
+		if base == "" {
+			base = DefaultWorkspaceBase
+		}
+		opts = append(opts, WithWorkspace(base, path))
+	}
+	return opts
+}
@@ -0,0 +1,34 @@
+package bitbucket
+
+import "testing"
+
+func TestRequestOptions(t *testing.T) {
+	conf, err := ParseString("pipelines:\n  default:\n    - step:\n        script: [ go test ]\n")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	tests := []struct {
+		opts       RequestOptions
+		name, want string
+	}{
+		{RequestOptions{}, "pipeline_step_0", "/workspace/src"},
+		{RequestOptions{Prefix: "test", WorkspaceBase: "/go", WorkspacePath: "src/app"}, "test_step_0", "/go/src/app"},
+		{RequestOptions{WorkspacePath: "src/app"}, "pipeline_step_0", "/workspace/src/app"},
+	}
+	for _, test := range tests {
+		test.opts.Local = true
+		compiled, err := NewCompiler(test.opts.Options()...).Compile(conf)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		step := compiled.Stages[0].Steps[0]
+		if want, got := test.name, step.Name; want != got {
+			t.Errorf("Wanted step name %s, got %s", want, got)
+		}
+		if want, got := test.want, step.WorkingDir; want != got {
+			t.Errorf("Wanted working dir %s, got %s", want, got)
+		}
+	}
+}
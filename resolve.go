@@ -0,0 +1,151 @@
+package bitbucket
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigResolver resolves the configuration file of a repository at
+// the given ref. It is used to import pipelines exported by other
+// repositories.
+type ConfigResolver interface {
+	Resolve(repo, ref string) ([]byte, error)
+}
+
+// FileResolver resolves configuration files from the local filesystem.
+// The configuration of a repository is read from the file
+// <root>/<repo>/<ref>/bitbucket-pipelines.yml.
+type FileResolver struct {
+	root string
+}
+
+// NewFileResolver returns a new FileResolver that reads configuration
+// files below the root directory.
+func NewFileResolver(root string) *FileResolver {
+	return &FileResolver{root: root}
+}
+
+// Path returns the path of the configuration file for the repository
+// at the given ref.
+func (r *FileResolver) Path(repo, ref string) string {
+	return filepath.Join(r.root, repo, ref, "bitbucket-pipelines.yml")
+}
+
+// Resolve reads the configuration file for the repository at the
+// given ref. The repository and ref must be relative paths below the
+// root, so that an import cannot read files outside the root.
+func (r *FileResolver) Resolve(repo, ref string) ([]byte, error) {
+	for _, name := range []string{repo, ref} {
+		if !isRelativePath(name) {
+			return nil, fmt.Errorf("invalid path %q", name)
+		}
+	}
+	return ioutil.ReadFile(r.Path(repo, ref))
+}
+
+// isRelativePath returns true if the path is relative and none of its
+// elements refers to a parent directory.
+func isRelativePath(name string) bool {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") {
+		return false
+	}
+	for _, elem := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveImports replaces every imported pipeline in the configuration
+// with the steps of the exported pipeline.
+func resolveImports(conf *Config, resolver ConfigResolver) error {
+	i := &importer{resolver: resolver}
+	if err := i.resolve(&conf.Pipelines.Default); err != nil {
+		return err
+	}
+	for _, pipelines := range conf.sections() {
+		for pattern, stage := range pipelines {
+			if err := i.resolve(&stage); err != nil {
+				return err
+			}
+			pipelines[pattern] = stage
+		}
+	}
+	return nil
+}
+
+// importer resolves imported pipelines and tracks the chain of imports
+// to detect cycles.
+type importer struct {
+	resolver ConfigResolver
+	stack    []string
+}
+
+// resolve populates the steps of the stage if the stage is imported.
+func (i *importer) resolve(stage *Stage) error {
+	if stage.Import == "" {
+		return nil
+	}
+	for _, ref := range i.stack {
+		if ref == stage.Import {
+			chain := append(i.stack, stage.Import)
+			return fmt.Errorf("import cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	if i.resolver == nil {
+		return fmt.Errorf("cannot import %s: no config resolver", stage.Import)
+	}
+
+	repo, ref, name, err := splitImport(stage.Import)
+	if err != nil {
+		return err
+	}
+	raw, err := i.resolver.Resolve(repo, ref)
+	if err != nil {
+		return fmt.Errorf("cannot import %s: %s", stage.Import, err)
+	}
+	conf := new(Config)
+	if err := yaml.Unmarshal(raw, conf); err != nil {
+		return fmt.Errorf("cannot import %s: %s", stage.Import, err)
+	}
+	if conf.Export == false {
+		return fmt.Errorf("cannot import %s: %s:%s does not export pipelines", stage.Import, repo, ref)
+	}
+	exported, ok := conf.Definitions.Pipelines[name]
+	if !ok {
+		return fmt.Errorf("cannot import %s: no exported pipeline named %s", stage.Import, name)
+	}
+
+	i.stack = append(i.stack, stage.Import)
+	err = i.resolve(&exported)
+	i.stack = i.stack[:len(i.stack)-1]
+	if err != nil {
+		return err
+	}
+
+	// imported steps run with the default image of the
+	// exporting configuration.
+	stage.Steps = nil
+	for _, step := range exported.Steps {
+		imported := *step
+		if imported.Image == "" {
+			imported.Image = conf.Image
+		}
+		stage.Steps = append(stage.Steps, &imported)
+	}
+	return nil
+}
+
+// splitImport splits an import reference in the format repo:ref:name.
+func splitImport(s string) (repo, ref, name string, err error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("invalid import %q: expected repo:ref:name", s)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
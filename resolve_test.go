@@ -0,0 +1,107 @@
+package bitbucket
+
+import "testing"
+
+func TestResolveImports(t *testing.T) {
+	config, err := ParseString(importYaml,
+		WithResolver(NewFileResolver("testdata/imports")),
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	steps := config.Pipelines.Default.Steps
+	if want, got := 2, len(steps); want != got {
+		t.Errorf("Wanted %d imported steps, got %d", want, got)
+		t.FailNow()
+	}
+	if want, got := "golang:1.8", steps[0].Image; want != got {
+		t.Errorf("Wanted imported step to use the exporting image %s, got %s", want, got)
+	}
+	if want, got := "node:7.4.0", steps[1].Image; want != got {
+		t.Errorf("Wanted imported step image %s, got %s", want, got)
+	}
+
+	steps = config.Pipelines.Tags["release-*"].Steps
+	if want, got := 2, len(steps); want != got {
+		t.Errorf("Wanted %d steps from nested import, got %d", want, got)
+	}
+
+	steps = config.Pipelines.Branches["staging"].Steps
+	if want, got := 1, len(steps); want != got {
+		t.Errorf("Wanted %d steps in the local pipeline, got %d", want, got)
+	}
+}
+
+func TestResolveImportErrors(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{
+			ref:  "shared:master:ping",
+			want: "import cycle: shared:master:ping -> shared:master:pong -> shared:master:ping",
+		},
+		{
+			ref:  "shared:master:missing",
+			want: "cannot import shared:master:missing: no exported pipeline named missing",
+		},
+		{
+			ref:  "private:master:build",
+			want: "cannot import private:master:build: private:master does not export pipelines",
+		},
+		{
+			ref:  "../../etc:x:y",
+			want: `cannot import ../../etc:x:y: invalid path "../../etc"`,
+		},
+		{
+			ref:  "shared:../../../..:build",
+			want: `cannot import shared:../../../..:build: invalid path "../../../.."`,
+		},
+		{
+			ref:  "/etc:x:y",
+			want: `cannot import /etc:x:y: invalid path "/etc"`,
+		},
+		{
+			ref:  "shared:build",
+			want: `invalid import "shared:build": expected repo:ref:name`,
+		},
+	}
+	for _, test := range tests {
+		_, err := ParseString(
+			"pipelines:\n  default:\n    import: "+test.ref,
+			WithResolver(NewFileResolver("testdata/imports")),
+		)
+		if err == nil {
+			t.Errorf("Expect error %q", test.want)
+			continue
+		}
+		if got := err.Error(); got != test.want {
+			t.Errorf("Wanted error %q, got %q", test.want, got)
+		}
+	}
+}
+
+func TestResolveImportsWithoutResolver(t *testing.T) {
+	_, err := ParseString(importYaml)
+	if err == nil {
+		t.Errorf("Expect error importing a pipeline without a resolver")
+	}
+}
+
+var importYaml = `
+image: node:latest
+
+pipelines:
+  default:
+    import: shared:master:build
+  tags:
+    release-*:
+      import: shared:master:release
+  branches:
+    staging:
+      - step:
+          script:
+            - echo "Clone all the things!"
+`
@@ -0,0 +1,209 @@
+package rpc
+
+import (
+	"github.com/cncd/bitbucket-frontend"
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+// compilerOptions returns the compiler options of the request. The
+// options are the same as for an http compile request.
+func compilerOptions(req *Request) []bitbucket.Option {
+	opts := req.GetOptions()
+	r := &bitbucket.RequestOptions{
+		Metadata:      toMetadata(req.GetMetadata()),
+		Prefix:        opts.GetPrefix(),
+		WorkspaceBase: opts.GetWorkspace().GetBase(),
+		WorkspacePath: opts.GetWorkspace().GetPath(),
+		Volumes:       opts.GetVolumes(),
+		NetrcUsername: opts.GetNetrc().GetUsername(),
+		NetrcPassword: opts.GetNetrc().GetPassword(),
+		NetrcMachine:  opts.GetNetrc().GetMachine(),
+		Environ:       opts.GetEnviron(),
+		Local:         opts.GetLocal(),
+		Manual:        opts.GetManual(),
+	}
+	return r.Options()
+}
+
+// toMetadata returns the build metadata of the message.
+func toMetadata(m *Metadata) frontend.Metadata {
+	return frontend.Metadata{
+		ID: m.GetId(),
+		Repo: frontend.Repo{
+			Name:    m.GetRepo().GetName(),
+			Link:    m.GetRepo().GetLink(),
+			Remote:  m.GetRepo().GetRemote(),
+			Private: m.GetRepo().GetPrivate(),
+		},
+		Curr: toBuild(m.GetCurr()),
+		Prev: toBuild(m.GetPrev()),
+		Job: frontend.Job{
+			Number: int(m.GetJob().GetNumber()),
+			Matrix: m.GetJob().GetMatrix(),
+		},
+		Sys: frontend.System{
+			Name:    m.GetSys().GetName(),
+			Host:    m.GetSys().GetHost(),
+			Link:    m.GetSys().GetLink(),
+			Arch:    m.GetSys().GetArch(),
+			Version: m.GetSys().GetVersion(),
+		},
+	}
+}
+
+// toBuild returns the build metadata of the message.
+func toBuild(b *Build) frontend.Build {
+	commit := b.GetCommit()
+	return frontend.Build{
+		Number:   int(b.GetNumber()),
+		Created:  b.GetCreated(),
+		Started:  b.GetStarted(),
+		Finished: b.GetFinished(),
+		Timeout:  b.GetTimeout(),
+		Status:   b.GetStatus(),
+		Event:    b.GetEvent(),
+		Link:     b.GetLink(),
+		Target:   b.GetTarget(),
+		Commit: frontend.Commit{
+			Sha:     commit.GetSha(),
+			Ref:     commit.GetRef(),
+			Refspec: commit.GetRefspec(),
+			Branch:  commit.GetBranch(),
+			Message: commit.GetMessage(),
+			Author: frontend.Author{
+				Name:   commit.GetAuthor().GetName(),
+				Email:  commit.GetAuthor().GetEmail(),
+				Avatar: commit.GetAuthor().GetAvatar(),
+			},
+		},
+	}
+}
+
+// toPipeline returns the message of the compiled pipeline.
+func toPipeline(spec *backend.Config) *Pipeline {
+	out := new(Pipeline)
+	for _, stage := range spec.Stages {
+		s := &Stage{Name: stage.Name, Alias: stage.Alias}
+		for _, step := range stage.Steps {
+			s.Steps = append(s.Steps, toStep(step))
+		}
+		out.Stages = append(out.Stages, s)
+	}
+	for _, network := range spec.Networks {
+		out.Networks = append(out.Networks, &Network{
+			Name:       network.Name,
+			Driver:     network.Driver,
+			DriverOpts: network.DriverOpts,
+		})
+	}
+	for _, volume := range spec.Volumes {
+		out.Volumes = append(out.Volumes, &Volume{
+			Name:       volume.Name,
+			Driver:     volume.Driver,
+			DriverOpts: volume.DriverOpts,
+		})
+	}
+	for _, secret := range spec.Secrets {
+		out.Secrets = append(out.Secrets, &Secret{
+			Name:  secret.Name,
+			Value: secret.Value,
+			Mount: secret.Mount,
+		})
+	}
+	return out
+}
+
+// toStep returns the message of the compiled step.
+func toStep(step *backend.Step) *Step {
+	out := &Step{
+		Name:         step.Name,
+		Alias:        step.Alias,
+		Image:        step.Image,
+		Pull:         step.Pull,
+		Detached:     step.Detached,
+		Privileged:   step.Privileged,
+		WorkingDir:   step.WorkingDir,
+		Environment:  step.Environment,
+		Labels:       step.Labels,
+		Entrypoint:   step.Entrypoint,
+		Command:      step.Command,
+		ExtraHosts:   step.ExtraHosts,
+		Volumes:      step.Volumes,
+		Tmpfs:        step.Tmpfs,
+		Devices:      step.Devices,
+		Dns:          step.DNS,
+		DnsSearch:    step.DNSSearch,
+		MemSwapLimit: step.MemSwapLimit,
+		MemLimit:     step.MemLimit,
+		ShmSize:      step.ShmSize,
+		CpuQuota:     step.CPUQuota,
+		CpuShares:    step.CPUShares,
+		CpuSet:       step.CPUSet,
+		OnFailure:    step.OnFailure,
+		OnSuccess:    step.OnSuccess,
+		AuthConfig: &Auth{
+			Username: step.AuthConfig.Username,
+			Password: step.AuthConfig.Password,
+			Email:    step.AuthConfig.Email,
+		},
+		NetworkMode: step.NetworkMode,
+	}
+	for _, conn := range step.Networks {
+		out.Networks = append(out.Networks, &Conn{
+			Name:    conn.Name,
+			Aliases: conn.Aliases,
+		})
+	}
+	return out
+}
+
+// toDiagnostics returns the messages of the diagnostics.
+func toDiagnostics(diagnostics []*bitbucket.Diagnostic) []*Diagnostic {
+	var out []*Diagnostic
+	for _, d := range diagnostics {
+		out = append(out, &Diagnostic{
+			Pipeline: d.Pipeline,
+			Step:     d.Step,
+			Message:  d.Message,
+		})
+	}
+	return out
+}
+
+// toPlan returns the message of the plan.
+func toPlan(plan *bitbucket.Plan) *Plan {
+	out := &Plan{
+		Ref:       plan.Ref,
+		Branch:    plan.Branch,
+		Selector:  plan.Selector.String(),
+		Workspace: plan.Workspace,
+		Volumes:   plan.Volumes,
+	}
+	for _, candidate := range plan.Candidates {
+		out.Candidates = append(out.Candidates, &Candidate{
+			Selector: candidate.Selector.String(),
+			Matched:  candidate.Matched,
+		})
+	}
+	if plan.Clone != nil {
+		out.Clone = &PlanClone{
+			Image: plan.Clone.Image,
+			Depth: int64(plan.Clone.Depth),
+		}
+	}
+	for _, step := range plan.Steps {
+		out.Steps = append(out.Steps, &PlanStep{
+			Name:       step.Name,
+			Alias:      step.Alias,
+			Image:      step.Image,
+			Platform:   step.Platform,
+			Deployment: step.Deployment,
+			Commands:   step.Commands,
+			Manual:     step.Manual,
+			Skipped:    step.Skipped,
+			Script:     step.Script,
+		})
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package rpc
+
+import (
+	"testing"
+
+	"github.com/cncd/bitbucket-frontend"
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+func TestToMetadata(t *testing.T) {
+	m := toMetadata(&Metadata{
+		Repo: &Repo{Name: "octocat/hello-world", Private: true},
+		Curr: &Build{
+			Number: 42,
+			Event:  "push",
+			Commit: &Commit{
+				Branch: "master",
+				Author: &Author{Name: "octocat"},
+			},
+		},
+		Job: &Job{Number: 2, Matrix: map[string]string{"GO": "1.9"}},
+	})
+	for _, test := range []struct {
+		name      string
+		want, got interface{}
+	}{
+		{"repo name", "octocat/hello-world", m.Repo.Name},
+		{"repo private", true, m.Repo.Private},
+		{"build number", 42, m.Curr.Number},
+		{"build event", "push", m.Curr.Event},
+		{"branch", "master", m.Curr.Commit.Branch},
+		{"author", "octocat", m.Curr.Commit.Author.Name},
+		{"job number", 2, m.Job.Number},
+		{"job matrix", "1.9", m.Job.Matrix["GO"]},
+	} {
+		if test.want != test.got {
+			t.Errorf("Wanted %s %v, got %v", test.name, test.want, test.got)
+		}
+	}
+
+	// unset messages are converted to empty metadata.
+	if m := toMetadata(nil); m.Repo.Name != "" || m.Curr.Number != 0 {
+		t.Errorf("Expect empty metadata, got %+v", m)
+	}
+}
+
+func TestToPipeline(t *testing.T) {
+	out := toPipeline(&backend.Config{
+		Stages: []*backend.Stage{{
+			Name: "test_stage_0",
+			Steps: []*backend.Step{{
+				Name:        "test_step_0",
+				Image:       "golang:1.9",
+				Environment: map[string]string{"CI": "true"},
+				Networks:    []backend.Conn{{Name: "test_default", Aliases: []string{"build"}}},
+				DNSSearch:   []string{"example.com"},
+				CPUQuota:    100,
+				AuthConfig:  backend.Auth{Username: "octocat"},
+			}},
+		}},
+		Volumes: []*backend.Volume{{Name: "test_default", Driver: "local"}},
+		Secrets: []*backend.Secret{{Name: "TOKEN"}},
+	})
+
+	step := out.Stages[0].Steps[0]
+	for _, test := range []struct {
+		name      string
+		want, got interface{}
+	}{
+		{"stage", "test_stage_0", out.Stages[0].Name},
+		{"step", "test_step_0", step.Name},
+		{"image", "golang:1.9", step.Image},
+		{"environment", "true", step.Environment["CI"]},
+		{"network", "build", step.Networks[0].Aliases[0]},
+		{"dns search", "example.com", step.DnsSearch[0]},
+		{"cpu quota", int64(100), step.CpuQuota},
+		{"auth", "octocat", step.AuthConfig.Username},
+		{"volume", "local", out.Volumes[0].Driver},
+		{"secret", "TOKEN", out.Secrets[0].Name},
+	} {
+		if test.want != test.got {
+			t.Errorf("Wanted %s %v, got %v", test.name, test.want, test.got)
+		}
+	}
+}
+
+func TestToPlan(t *testing.T) {
+	out := toPlan(&bitbucket.Plan{
+		Ref:      "refs/heads/master",
+		Selector: bitbucket.Selector{Section: bitbucket.SectionBranches, Pattern: "master"},
+		Candidates: []*bitbucket.Candidate{
+			{Selector: bitbucket.Selector{Section: bitbucket.SectionDefault}},
+		},
+		Clone: &bitbucket.PlanClone{Image: "plugins/git:latest", Depth: 50},
+		Steps: []*bitbucket.PlanStep{{Name: "build", Manual: true}},
+	})
+	if want, got := "branches/master", out.Selector; want != got {
+		t.Errorf("Wanted selector %s, got %s", want, got)
+	}
+	if want, got := "default", out.Candidates[0].Selector; want != got {
+		t.Errorf("Wanted candidate %s, got %s", want, got)
+	}
+	if want, got := int64(50), out.Clone.Depth; want != got {
+		t.Errorf("Wanted clone depth %d, got %d", want, got)
+	}
+	if !out.Steps[0].Manual {
+		t.Errorf("Expect manual step")
+	}
+}
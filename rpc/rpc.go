@@ -0,0 +1,144 @@
+// Package rpc exposes the compiler as a gRPC service. The service and
+// its messages are defined in compiler.proto, from which the Go stubs
+// are generated.
+package rpc
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative ../rpc/compiler.proto
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cncd/bitbucket-frontend"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the compiler service.
+type Server struct {
+	UnimplementedCompilerServer
+
+	resolver bitbucket.ConfigResolver
+}
+
+// Option configures a server option.
+type Option func(*Server)
+
+// WithResolver configures the server with the resolver used to import
+// pipelines exported by other repositories.
+func WithResolver(resolver bitbucket.ConfigResolver) Option {
+	return func(s *Server) {
+		s.resolver = resolver
+	}
+}
+
+// New returns a new Server.
+func New(opts ...Option) *Server {
+	s := new(Server)
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register registers the compiler service with the grpc server.
+func (s *Server) Register(g *grpc.Server) {
+	RegisterCompilerServer(g, s)
+}
+
+// Compile compiles the pipeline selected by the metadata.
+func (s *Server) Compile(ctx context.Context, req *Request) (*CompileResponse, error) {
+	conf, err := s.parse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := bitbucket.NewCompiler(compilerOptions(req)...).Compile(conf)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &CompileResponse{Pipeline: toPipeline(compiled)}, nil
+}
+
+// CompileAll compiles every pipeline of the configuration.
+func (s *Server) CompileAll(ctx context.Context, req *Request) (*CompileAllResponse, error) {
+	conf, err := s.parse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := bitbucket.NewCompiler(compilerOptions(req)...).CompileAll(conf)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+	res := &CompileAllResponse{Pipelines: map[string]*Pipeline{}}
+	for selector, spec := range compiled {
+		res.Pipelines[selector.String()] = toPipeline(spec)
+	}
+	return res, nil
+}
+
+// Lint returns the diagnostics of the configuration.
+func (s *Server) Lint(ctx context.Context, req *Request) (*LintResponse, error) {
+	conf, err := s.parse(ctx, req)
+	if status.Code(err) == codes.InvalidArgument {
+		return &LintResponse{
+			Diagnostics: []*Diagnostic{
+				{Message: status.Convert(err).Message()},
+			},
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &LintResponse{Diagnostics: toDiagnostics(bitbucket.Lint(conf))}, nil
+}
+
+// Plan explains the pipeline selected for the metadata.
+func (s *Server) Plan(ctx context.Context, req *Request) (*PlanResponse, error) {
+	conf, err := s.parse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := bitbucket.NewCompiler(compilerOptions(req)...).Plan(conf)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &PlanResponse{Plan: toPlan(plan)}, nil
+}
+
+// parse parses the configuration of the request. Invalid
+// configurations are reported with the InvalidArgument code.
+func (s *Server) parse(ctx context.Context, req *Request) (*bitbucket.Config, error) {
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+	if req.Config == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing config")
+	}
+	var opts []bitbucket.ParseOption
+	if s.resolver != nil {
+		opts = append(opts, bitbucket.WithResolver(s.resolver))
+	}
+	conf, err := bitbucket.ParseString(req.Config, opts...)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return conf, nil
+}
+
+// contextError returns the status error of a cancelled or expired
+// context, or nil.
+func contextError(ctx context.Context) error {
+	switch err := ctx.Err(); {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return nil
+	}
+}
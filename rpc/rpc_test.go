@@ -0,0 +1,169 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial starts an in-process server and returns a client connected to
+// it, and a function that stops the server.
+func dial(t *testing.T) (CompilerClient, func()) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	New().Register(srv)
+	go srv.Serve(lis)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCompilerClient(conn), func() {
+		conn.Close()
+		srv.Stop()
+	}
+}
+
+func testRequest() *Request {
+	return &Request{
+		Config: rpcYaml,
+		Metadata: &Metadata{
+			Curr: &Build{
+				Commit: &Commit{
+					Ref:    "refs/heads/master",
+					Branch: "master",
+				},
+			},
+		},
+		Options: &Options{Prefix: "test"},
+	}
+}
+
+func TestCompile(t *testing.T) {
+	client, stop := dial(t)
+	defer stop()
+
+	res, err := client.Compile(context.Background(), testRequest())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := 2, len(res.Pipeline.Stages); want != got {
+		t.Errorf("Wanted %d stages, got %d", want, got)
+		return
+	}
+	step := res.Pipeline.Stages[1].Steps[0]
+	if want, got := "golang:1.8", step.Image; want != got {
+		t.Errorf("Wanted image %s, got %s", want, got)
+	}
+	if want, got := "test_step_0", step.Name; want != got {
+		t.Errorf("Wanted step name %s, got %s", want, got)
+	}
+	if want, got := "master", step.Environment["CI_COMMIT_BRANCH"]; want != got {
+		t.Errorf("Wanted branch %s, got %s", want, got)
+	}
+}
+
+func TestCompileAll(t *testing.T) {
+	client, stop := dial(t)
+	defer stop()
+
+	res, err := client.CompileAll(context.Background(), testRequest())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := 2, len(res.Pipelines); want != got {
+		t.Errorf("Wanted %d pipelines, got %d", want, got)
+	}
+	if _, ok := res.Pipelines["branches/master"]; !ok {
+		t.Errorf("Expect the master branch pipeline")
+	}
+}
+
+func TestPlan(t *testing.T) {
+	client, stop := dial(t)
+	defer stop()
+
+	res, err := client.Plan(context.Background(), testRequest())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := "branches/master", res.Plan.Selector; want != got {
+		t.Errorf("Wanted selector %s, got %s", want, got)
+	}
+}
+
+func TestLint(t *testing.T) {
+	client, stop := dial(t)
+	defer stop()
+
+	req := testRequest()
+	req.Config = "pipelines: ["
+	res, err := client.Lint(context.Background(), req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := 1, len(res.Diagnostics); want != got {
+		t.Errorf("Wanted %d diagnostics, got %d", want, got)
+	}
+}
+
+func TestInvalidArgument(t *testing.T) {
+	client, stop := dial(t)
+	defer stop()
+
+	req := testRequest()
+	req.Config = "pipelines: ["
+	_, err := client.Compile(context.Background(), req)
+	if want, got := codes.InvalidArgument, status.Code(err); want != got {
+		t.Errorf("Wanted code %s, got %s", want, got)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	client, stop := dial(t)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	_, err := client.Compile(ctx, testRequest())
+	if want, got := codes.DeadlineExceeded, status.Code(err); want != got {
+		t.Errorf("Wanted code %s, got %s", want, got)
+	}
+
+	// the server stops work for requests that are cancelled
+	// before they are handled.
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = New().CompileAll(ctx, testRequest())
+	if want, got := codes.Canceled, status.Code(err); want != got {
+		t.Errorf("Wanted code %s, got %s", want, got)
+	}
+}
+
+var rpcYaml = `
+pipelines:
+  default:
+    - step:
+        script: [ make ]
+  branches:
+    master:
+      - step:
+          image: golang:1.8
+          script: [ go test ]
+`
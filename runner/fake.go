@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+// FakeEngine is an engine that records the steps that would run without
+// running them. It is used for dry runs and tests.
+type FakeEngine struct {
+	// Logs contains the logs returned for each step by name.
+	Logs map[string]string
+
+	// ExitCodes contains the exit code returned for each step
+	// by name. Steps exit with code zero by default.
+	ExitCodes map[string]int
+
+	// ExecErrors contains the error returned by Exec for each
+	// step by name.
+	ExecErrors map[string]error
+
+	mu    sync.Mutex
+	calls []string
+	steps []*backend.Step
+}
+
+// NewFakeEngine returns a new FakeEngine.
+func NewFakeEngine() *FakeEngine {
+	return &FakeEngine{
+		Logs:       map[string]string{},
+		ExitCodes:  map[string]int{},
+		ExecErrors: map[string]error{},
+	}
+}
+
+// Calls returns the engine calls in the order they were made.
+func (e *FakeEngine) Calls() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.calls...)
+}
+
+// Steps returns the steps that were executed in the order they were
+// executed.
+func (e *FakeEngine) Steps() []*backend.Step {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*backend.Step(nil), e.steps...)
+}
+
+// Setup records the setup of the pipeline.
+func (e *FakeEngine) Setup(ctx context.Context, spec *backend.Config) error {
+	e.record("setup")
+	return nil
+}
+
+// Exec records the execution of the step, and returns the configured
+// error of the step.
+func (e *FakeEngine) Exec(ctx context.Context, step *backend.Step) error {
+	e.mu.Lock()
+	e.steps = append(e.steps, step)
+	err := e.ExecErrors[step.Name]
+	e.mu.Unlock()
+	e.record("exec " + step.Name)
+	return err
+}
+
+// Kill records that the step was killed.
+func (e *FakeEngine) Kill(ctx context.Context, step *backend.Step) error {
+	e.record("kill " + step.Name)
+	return nil
+}
+
+// Wait returns the configured exit code of the step.
+func (e *FakeEngine) Wait(ctx context.Context, step *backend.Step) (*backend.State, error) {
+	e.record("wait " + step.Name)
+	e.mu.Lock()
+	code := e.ExitCodes[step.Name]
+	e.mu.Unlock()
+	return &backend.State{Exited: true, ExitCode: code}, nil
+}
+
+// Tail returns the configured logs of the step.
+func (e *FakeEngine) Tail(ctx context.Context, step *backend.Step) (io.ReadCloser, error) {
+	e.record("tail " + step.Name)
+	e.mu.Lock()
+	logs := e.Logs[step.Name]
+	e.mu.Unlock()
+	return ioutil.NopCloser(strings.NewReader(logs)), nil
+}
+
+// Destroy records the teardown of the pipeline.
+func (e *FakeEngine) Destroy(ctx context.Context, spec *backend.Config) error {
+	e.record("destroy")
+	return nil
+}
+
+// String returns a summary of the executed steps.
+func (e *FakeEngine) String() string {
+	var buf strings.Builder
+	for _, step := range e.Steps() {
+		fmt.Fprintf(&buf, "%s: %s %s\n", step.Name, step.Image, strings.Join(step.Command, " "))
+	}
+	return buf.String()
+}
+
+func (e *FakeEngine) record(call string) {
+	e.mu.Lock()
+	e.calls = append(e.calls, call)
+	e.mu.Unlock()
+}
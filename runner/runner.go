@@ -0,0 +1,228 @@
+// Package runner executes a compiled pipeline with a pluggable engine.
+package runner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+type (
+	// Runner executes the stages of a compiled pipeline in sequence
+	// and the steps of a stage in parallel.
+	Runner struct {
+		engine  backend.Engine
+		output  io.Writer
+		secrets map[string]string
+		mu      sync.Mutex
+	}
+
+	// Result is the result of a pipeline step.
+	Result struct {
+		Name     string
+		ExitCode int
+		Skipped  bool
+
+		// Error is the error of the engine if the step could not
+		// be executed or its logs could not be read.
+		Error error
+	}
+
+	// ExitError is returned when a pipeline step exits with a
+	// non-zero exit code.
+	ExitError struct {
+		Name string
+		Code int
+	}
+
+	// Option configures a runner option.
+	Option func(*Runner)
+)
+
+// Error implements the error interface.
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("%s : exit code %d", e.Name, e.Code)
+}
+
+// WithOutput configures the runner to write the logs of each step to
+// the writer. Each line is prefixed with the alias of the step.
+func WithOutput(w io.Writer) Option {
+	return func(r *Runner) {
+		r.output = w
+	}
+}
+
+// WithSecrets configures the runner with the secret values. Secrets
+// declared by the compiled pipeline are added to the environment of
+// every step, and secret values are masked in the logs.
+func WithSecrets(secrets map[string]string) Option {
+	return func(r *Runner) {
+		r.secrets = secrets
+	}
+}
+
+// New returns a new Runner that executes pipelines with the engine.
+func New(engine backend.Engine, opts ...Option) *Runner {
+	r := &Runner{
+		engine: engine,
+		output: ioutil.Discard,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run executes the pipeline and returns the result of each step. An
+// ExitError is returned if a step exits with a non-zero exit code.
+func (r *Runner) Run(ctx context.Context, spec *backend.Config) ([]*Result, error) {
+	spec = r.inject(spec)
+
+	if err := r.engine.Setup(ctx, spec); err != nil {
+		return nil, err
+	}
+	defer r.engine.Destroy(context.Background(), spec)
+
+	var results []*Result
+	var failure error
+	for _, stage := range spec.Stages {
+		stageResults := make([]*Result, len(stage.Steps))
+		errs := make([]error, len(stage.Steps))
+
+		var wg sync.WaitGroup
+		for i, step := range stage.Steps {
+			if (failure == nil && !step.OnSuccess) || (failure != nil && !step.OnFailure) {
+				stageResults[i] = &Result{Name: step.Name, Skipped: true}
+				continue
+			}
+			wg.Add(1)
+			go func(i int, step *backend.Step) {
+				defer wg.Done()
+				stageResults[i], errs[i] = r.exec(ctx, step)
+			}(i, step)
+		}
+		wg.Wait()
+
+		results = append(results, stageResults...)
+		for _, err := range errs {
+			if err != nil && failure == nil {
+				failure = err
+			}
+		}
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+	}
+	return results, failure
+}
+
+// exec executes the step, streams the logs and waits for the step
+// to exit. The result is returned even if the engine fails.
+func (r *Runner) exec(ctx context.Context, step *backend.Step) (*Result, error) {
+	result := &Result{Name: step.Name}
+	fail := func(err error) (*Result, error) {
+		result.Error = err
+		return result, err
+	}
+
+	if err := r.engine.Exec(ctx, step); err != nil {
+		return fail(err)
+	}
+
+	rc, err := r.engine.Tail(ctx, step)
+	if err != nil {
+		r.kill(ctx, step)
+		return fail(err)
+	}
+	streamErr := r.stream(step, rc)
+	rc.Close()
+
+	state, err := r.engine.Wait(ctx, step)
+	if err != nil {
+		r.kill(ctx, step)
+		return fail(err)
+	}
+
+	result.ExitCode = state.ExitCode
+	if state.ExitCode != 0 {
+		return result, &ExitError{Name: step.Name, Code: state.ExitCode}
+	}
+	if streamErr != nil {
+		return fail(fmt.Errorf("%s : reading logs: %s", step.Name, streamErr))
+	}
+	return result, nil
+}
+
+// kill kills the step if the context is cancelled.
+func (r *Runner) kill(ctx context.Context, step *backend.Step) {
+	if ctx.Err() != nil {
+		r.engine.Kill(context.Background(), step)
+	}
+}
+
+// stream writes the logs of the step to the output, masking secrets.
+// Lines are not limited in length. If reading the logs fails, the rest
+// of the logs is drained so the step is not blocked writing them.
+func (r *Runner) stream(step *backend.Step, rc io.Reader) error {
+	prefix := step.Alias
+	if prefix == "" {
+		prefix = step.Name
+	}
+	reader := bufio.NewReader(rc)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(line, "\n")
+			for _, value := range r.secrets {
+				if value != "" {
+					line = strings.Replace(line, value, "********", -1)
+				}
+			}
+			r.mu.Lock()
+			fmt.Fprintf(r.output, "[%s] %s\n", prefix, line)
+			r.mu.Unlock()
+		}
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			io.Copy(ioutil.Discard, rc)
+			return err
+		}
+	}
+}
+
+// inject returns a copy of the pipeline with the declared secrets added
+// to the environment of every step.
+func (r *Runner) inject(spec *backend.Config) *backend.Config {
+	if len(spec.Secrets) == 0 {
+		return spec
+	}
+	out := *spec
+	out.Stages = nil
+	for _, stage := range spec.Stages {
+		s := *stage
+		s.Steps = nil
+		for _, step := range stage.Steps {
+			copied := *step
+			copied.Environment = map[string]string{}
+			for k, v := range step.Environment {
+				copied.Environment[k] = v
+			}
+			for _, secret := range spec.Secrets {
+				if value, ok := r.secrets[secret.Name]; ok {
+					copied.Environment[secret.Name] = value
+				}
+			}
+			s.Steps = append(s.Steps, &copied)
+		}
+		out.Stages = append(out.Stages, &s)
+	}
+	return &out
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+)
+
+func TestRun(t *testing.T) {
+	engine := NewFakeEngine()
+	engine.Logs["test_step_0"] = "+ go build\nok\n"
+
+	var buf bytes.Buffer
+	results, err := New(engine, WithOutput(&buf)).Run(context.Background(), testPipeline())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	want := []string{
+		"setup",
+		"exec test_step_0", "tail test_step_0", "wait test_step_0",
+		"exec test_step_1", "tail test_step_1", "wait test_step_1",
+		"destroy",
+	}
+	if got := engine.Calls(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Wanted calls %v, got %v", want, got)
+	}
+	if want, got := "[step_0] + go build\n[step_0] ok\n", buf.String(); want != got {
+		t.Errorf("Wanted output %q, got %q", want, got)
+	}
+	if want, got := 3, len(results); want != got {
+		t.Errorf("Wanted %d results, got %d", want, got)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	engine := NewFakeEngine()
+	engine.ExitCodes["test_step_0"] = 2
+
+	results, err := New(engine).Run(context.Background(), testPipeline())
+	exit, ok := err.(*ExitError)
+	if !ok {
+		t.Errorf("Expect exit error, got %v", err)
+		t.FailNow()
+	}
+	if exit.Name != "test_step_0" || exit.Code != 2 {
+		t.Errorf("Wanted test_step_0 exit code 2, got %s exit code %d", exit.Name, exit.Code)
+	}
+	if !results[1].Skipped {
+		t.Errorf("Expect step after failure skipped")
+	}
+	if results[2].Skipped {
+		t.Errorf("Expect on failure step executed")
+	}
+}
+
+func TestRunSecrets(t *testing.T) {
+	engine := NewFakeEngine()
+	engine.Logs["test_step_0"] = "token is hunter2\n"
+
+	spec := testPipeline()
+	spec.Secrets = []*backend.Secret{{Name: "TOKEN"}}
+
+	var buf bytes.Buffer
+	_, err := New(engine,
+		WithOutput(&buf),
+		WithSecrets(map[string]string{"TOKEN": "hunter2"}),
+	).Run(context.Background(), spec)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := "hunter2", engine.Steps()[0].Environment["TOKEN"]; want != got {
+		t.Errorf("Wanted secret injected into the step environment")
+	}
+	if _, ok := spec.Stages[0].Steps[0].Environment["TOKEN"]; ok {
+		t.Errorf("Expect compiled pipeline not modified")
+	}
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("Expect secret masked in the logs, got %q", buf.String())
+	}
+}
+
+func testPipeline() *backend.Config {
+	return &backend.Config{
+		Stages: []*backend.Stage{
+			{
+				Name: "test_stage_0",
+				Steps: []*backend.Step{
+					{Name: "test_step_0", Alias: "step_0", OnSuccess: true},
+				},
+			},
+			{
+				Name: "test_stage_1",
+				Steps: []*backend.Step{
+					{Name: "test_step_1", Alias: "step_1", OnSuccess: true},
+				},
+			},
+			{
+				Name: "test_stage_2",
+				Steps: []*backend.Step{
+					{Name: "test_step_2", Alias: "step_2", OnFailure: true},
+				},
+			},
+		},
+	}
+}
+
+func TestRunEngineError(t *testing.T) {
+	engine := NewFakeEngine()
+	engine.ExecErrors["test_step_0"] = errors.New("image not found")
+
+	results, err := New(engine).Run(context.Background(), testPipeline())
+	if err == nil || err.Error() != "image not found" {
+		t.Errorf("Expect engine error, got %v", err)
+	}
+	if want, got := 3, len(results); want != got {
+		t.Errorf("Wanted %d results, got %d", want, got)
+		t.FailNow()
+	}
+	for i, result := range results {
+		if result == nil {
+			t.Errorf("Expect result of step %d", i)
+			t.FailNow()
+		}
+	}
+	if results[0].Name != "test_step_0" || results[0].Error != err {
+		t.Errorf("Expect the engine error in the result of the failed step")
+	}
+	if !results[1].Skipped {
+		t.Errorf("Expect step after failure skipped")
+	}
+	if results[2].Skipped {
+		t.Errorf("Expect on failure step executed")
+	}
+}
+
+func TestRunLongLines(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	engine := NewFakeEngine()
+	engine.Logs["test_step_0"] = long + "\nok"
+
+	var buf bytes.Buffer
+	_, err := New(engine, WithOutput(&buf)).Run(context.Background(), testPipeline())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := "[step_0] "+long+"\n[step_0] ok\n", buf.String(); want != got {
+		t.Errorf("Expect long log lines written in full, got %d bytes", len(got))
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestStreamError(t *testing.T) {
+	r := New(NewFakeEngine())
+	err := r.stream(&backend.Step{Name: "test_step_0"}, io.MultiReader(strings.NewReader("partial\n"), failingReader{}))
+	if err == nil || err.Error() != "connection reset" {
+		t.Errorf("Expect the read error, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package bitbucket
+
+import (
+	"github.com/cncd/bitbucket-frontend/schema"
+)
+
+// Schema returns the json schema of the subset of the configuration
+// supported by the compiler. Keys the compiler does not understand are
+// not allowed.
+func Schema() *schema.Schema {
+	out := schema.GenerateYAML(Config{})
+	out.Title = "bitbucket-pipelines.yml"
+	out.Description = "Pipeline configuration supported by bitbucketc."
+	out.Definitions = map[string]*schema.Schema{"stage": stageSchema()}
+	return out
+}
+
+// JSONSchema returns a reference to the json schema of the stage, which
+// is defined once in the configuration schema.
+func (Stage) JSONSchema() *schema.Schema {
+	return &schema.Schema{Ref: "#/definitions/stage"}
+}
+
+// JSONSchema returns the json schema of the artifacts, which are either
+// a list of patterns or an object with the patterns in the paths key.
+func (Artifacts) JSONSchema() *schema.Schema {
+	paths := &schema.Schema{Type: "array", Items: &schema.Schema{Type: "string"}}
+	return &schema.Schema{
+		OneOf: []*schema.Schema{
+			paths,
+			{
+				Type: "object",
+				Properties: map[string]*schema.Schema{
+					"download": {Type: "boolean"},
+					"paths":    paths,
+				},
+				AdditionalProperties: false,
+			},
+		},
+	}
+}
+
+// stageSchema returns the json schema of a stage, which is either the
+// import of a pipeline exported by another repository or a list of
+// steps and stage groups. Parallel steps are not supported.
+func stageSchema() *schema.Schema {
+	step := &schema.Schema{
+		Type:                 "object",
+		Properties:           map[string]*schema.Schema{"step": stepSchema()},
+		AdditionalProperties: false,
+		Required:             []string{"step"},
+	}
+
+	// steps inside a stage group share the deployment and the
+	// trigger of the group.
+	grouped := stepSchema()
+	delete(grouped.Properties, "deployment")
+	delete(grouped.Properties, "trigger")
+	group := &schema.Schema{
+		Type: "object",
+		Properties: map[string]*schema.Schema{
+			"name":       {Type: "string"},
+			"deployment": {Type: "string"},
+			"trigger":    triggerSchema(),
+			"steps": {
+				Type: "array",
+				Items: &schema.Schema{
+					Type:                 "object",
+					Properties:           map[string]*schema.Schema{"step": grouped},
+					AdditionalProperties: false,
+					Required:             []string{"step"},
+				},
+			},
+		},
+		AdditionalProperties: false,
+		Required:             []string{"steps"},
+	}
+
+	return &schema.Schema{
+		OneOf: []*schema.Schema{
+			{
+				Type:                 "object",
+				Properties:           map[string]*schema.Schema{"import": {Type: "string"}},
+				AdditionalProperties: false,
+				Required:             []string{"import"},
+			},
+			{
+				Type: "array",
+				Items: &schema.Schema{
+					OneOf: []*schema.Schema{
+						step,
+						{
+							Type:                 "object",
+							Properties:           map[string]*schema.Schema{"stage": group},
+							AdditionalProperties: false,
+							Required:             []string{"stage"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// stepSchema returns the json schema of a step, restricting the trigger
+// and the runtime architecture to the supported values.
+func stepSchema() *schema.Schema {
+	out := schema.GenerateYAML(Step{})
+	out.Schema = ""
+	out.Properties["trigger"] = triggerSchema()
+	out.Properties["runtime"].Properties["cloud"].Properties["arch"].Enum = []string{ArchX86, ArchARM}
+	return out
+}
+
+// triggerSchema returns the json schema of a trigger.
+func triggerSchema() *schema.Schema {
+	return &schema.Schema{
+		Type: "string",
+		Enum: []string{TriggerAutomatic, TriggerManual},
+	}
+}
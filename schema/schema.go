@@ -0,0 +1,118 @@
+// Package schema generates JSON schemas from Go types.
+package schema
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Draft is the JSON schema draft of the generated schemas.
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a JSON schema.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+}
+
+// Provider is implemented by types that provide their own schema,
+// typically because they implement custom decoding.
+type Provider interface {
+	JSONSchema() *Schema
+}
+
+var providerType = reflect.TypeOf((*Provider)(nil)).Elem()
+
+// Generate returns the schema of the json encoding of the value.
+func Generate(v interface{}) *Schema {
+	out := reflectType(reflect.TypeOf(v), "json")
+	out.Schema = Draft
+	return out
+}
+
+// GenerateYAML returns the schema of the yaml encoding of the value.
+// Fields are named after their yaml tag, or the lowercased field name,
+// and are optional.
+func GenerateYAML(v interface{}) *Schema {
+	out := reflectType(reflect.TypeOf(v), "yaml")
+	out.Schema = Draft
+	return out
+}
+
+// reflectType returns the schema of the encoding of the type, with the
+// fields named after the struct tag.
+func reflectType(t reflect.Type, tag string) *Schema {
+	if t.Kind() != reflect.Ptr && t.Implements(providerType) {
+		return reflect.Zero(t).Interface().(Provider).JSONSchema()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return reflectType(t.Elem(), tag)
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: reflectType(t.Elem(), tag)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: reflectType(t.Elem(), tag)}
+	case reflect.Struct:
+		return reflectStruct(t, tag)
+	default:
+		return &Schema{}
+	}
+}
+
+// reflectStruct returns the schema of the encoding of the struct. Json
+// fields are named after their json tag, and fields without the
+// omitempty option are required. Yaml fields are named after their
+// yaml tag or the lowercased field name, as decoded by yaml.v2.
+func reflectStruct(t reflect.Type, tag string) *Schema {
+	out := &Schema{
+		Type:                 "object",
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: false,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, opts := field.Name, ""
+		if tag == "yaml" {
+			name = strings.ToLower(name)
+		}
+		if value, ok := field.Tag.Lookup(tag); ok {
+			if value == "-" {
+				continue
+			}
+			parts := strings.SplitN(value, ",", 2)
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			if len(parts) == 2 {
+				opts = parts[1]
+			}
+		}
+		out.Properties[name] = reflectType(field.Type, tag)
+		if tag == "json" && !strings.Contains(opts, "omitempty") {
+			out.Required = append(out.Required, name)
+		}
+	}
+	return out
+}
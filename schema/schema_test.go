@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+func TestGenerate(t *testing.T) {
+	type item struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels,omitempty"`
+	}
+	type document struct {
+		ID      int     `json:"id"`
+		Items   []*item `json:"items,omitempty"`
+		Enabled bool    `json:",omitempty"`
+		Ignored string  `json:"-"`
+		hidden  string
+	}
+
+	out, err := json.Marshal(Generate(document{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := `{"$schema":"http://json-schema.org/draft-07/schema#","type":"object","properties":{"Enabled":{"type":"boolean"},"id":{"type":"integer"},"items":{"type":"array","items":{"type":"object","properties":{"labels":{"type":"object","additionalProperties":{"type":"string"}},"name":{"type":"string"}},"additionalProperties":false,"required":["name"]}}},"additionalProperties":false,"required":["id"]}`
+	if got := string(out); want != got {
+		t.Errorf("Wanted schema %s, got %s", want, got)
+	}
+}
+
+func TestGenerateMetadata(t *testing.T) {
+	out := Generate(frontend.Metadata{})
+	commit := out.Properties["curr"].Properties["commit"]
+	if commit == nil {
+		t.Errorf("Expect schema of the current commit")
+		return
+	}
+	if want, got := "string", commit.Properties["branch"].Type; want != got {
+		t.Errorf("Wanted branch type %s, got %s", want, got)
+	}
+	if want, got := "boolean", out.Properties["repo"].Properties["private"].Type; want != got {
+		t.Errorf("Wanted private type %s, got %s", want, got)
+	}
+	if len(out.Required) != 0 {
+		t.Errorf("Expect every metadata property is optional")
+	}
+}
+
+type choice struct{}
+
+func (choice) JSONSchema() *Schema {
+	return &Schema{Type: "string", Enum: []string{"yes", "no"}}
+}
+
+func TestGenerateYAML(t *testing.T) {
+	type document struct {
+		MaxTime int `yaml:"max-time"`
+		Script  []string
+		Answer  choice
+		Ignored string `yaml:"-"`
+	}
+
+	out, err := json.Marshal(GenerateYAML(document{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := `{"$schema":"http://json-schema.org/draft-07/schema#","type":"object","properties":{"answer":{"type":"string","enum":["yes","no"]},"max-time":{"type":"integer"},"script":{"type":"array","items":{"type":"string"}}},"additionalProperties":false}`
+	if got := string(out); want != got {
+		t.Errorf("Wanted schema %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,65 @@
+package bitbucket
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the published schema")
+
+// schemaFile is the published json schema of the configuration.
+const schemaFile = "bitbucket-pipelines.schema.json"
+
+// TestSchema fails if the published schema does not match the schema
+// generated from the configuration types. Run the test with the update
+// flag to regenerate the published schema.
+func TestSchema(t *testing.T) {
+	got, err := json.MarshalIndent(Schema(), "", "  ")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	got = append(got, '\n')
+	if *update {
+		if err := ioutil.WriteFile(schemaFile, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(schemaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("%s does not match the configuration types, run go test -update", schemaFile)
+	}
+}
+
+// TestSchemaStage fails if the fields of the stage items and groups
+// parsed by the stage diverge from its hand written schema.
+func TestSchemaStage(t *testing.T) {
+	items := stageSchema().OneOf[1].Items.OneOf
+	group := items[1].Properties["stage"]
+
+	typ := reflect.TypeOf(stageItem{})
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.ToLower(typ.Field(i).Name)
+		if name == "parallel" {
+			continue
+		}
+		if items[0].Properties[name] == nil && items[1].Properties[name] == nil {
+			t.Errorf("Expect stage item property %s in the schema", name)
+		}
+	}
+	typ = reflect.TypeOf(stageGroup{})
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.ToLower(typ.Field(i).Name)
+		if group.Properties[name] == nil {
+			t.Errorf("Expect stage group property %s in the schema", name)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Package server exposes the compiler as an HTTP service.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cncd/bitbucket-frontend"
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+// default limits of the server.
+const (
+	DefaultMaxBytes = 1 << 20
+	DefaultTimeout  = 10 * time.Second
+	DefaultPrefix   = bitbucket.DefaultPrefix
+)
+
+type (
+	// Server compiles the configurations posted to the compile
+	// endpoint.
+	Server struct {
+		maxBytes int64
+		timeout  time.Duration
+		resolver bitbucket.ConfigResolver
+	}
+
+	// Option configures a server option.
+	Option func(*Server)
+
+	// Request is the body of a compile request.
+	Request struct {
+		Config   string            `json:"config"`
+		Metadata frontend.Metadata `json:"metadata"`
+		Options  Options           `json:"options"`
+	}
+
+	// Options are the compiler options of a compile request.
+	Options struct {
+		Prefix    string            `json:"prefix,omitempty"`
+		Workspace Workspace         `json:"workspace,omitempty"`
+		Volumes   []string          `json:"volumes,omitempty"`
+		Netrc     Netrc             `json:"netrc,omitempty"`
+		Environ   map[string]string `json:"environ,omitempty"`
+		Local     bool              `json:"local,omitempty"`
+		Manual    bool              `json:"manual,omitempty"`
+	}
+
+	// Workspace is the workspace base and path of the pipeline.
+	Workspace struct {
+		Base string `json:"base,omitempty"`
+		Path string `json:"path,omitempty"`
+	}
+
+	// Netrc are the netrc credentials added to every container.
+	Netrc struct {
+		Username string `json:"username,omitempty"`
+		Password string `json:"password,omitempty"`
+		Machine  string `json:"machine,omitempty"`
+	}
+
+	// Error is the body of a response to a failed request.
+	Error struct {
+		Diagnostics []*bitbucket.Diagnostic `json:"diagnostics"`
+	}
+)
+
+// WithMaxBytes configures the server with the maximum size of the
+// request body.
+func WithMaxBytes(n int64) Option {
+	return func(s *Server) {
+		s.maxBytes = n
+	}
+}
+
+// WithTimeout configures the server with the maximum duration of a
+// request.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.timeout = d
+	}
+}
+
+// WithResolver configures the server with the resolver used to import
+// pipelines exported by other repositories.
+func WithResolver(resolver bitbucket.ConfigResolver) Option {
+	return func(s *Server) {
+		s.resolver = resolver
+	}
+}
+
+// New returns a new Server.
+func New(opts ...Option) *Server {
+	s := &Server{
+		maxBytes: DefaultMaxBytes,
+		timeout:  DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Handler returns the http handler of the server. Configurations are
+// compiled by posting a Request to /compile, and /healthz reports the
+// health of the server.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/compile", s.handleCompile)
+	mux.HandleFunc("/healthz", handleHealth)
+	return http.TimeoutHandler(mux, s.timeout, `{"diagnostics":[{"message":"request timeout"}]}`)
+}
+
+// handleCompile compiles the configuration of the request and writes
+// the compiled pipeline, or the diagnostics if the request fails.
+func (s *Server) handleCompile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	req := new(Request)
+	body := http.MaxBytesReader(w, r.Body, s.maxBytes)
+	if err := json.NewDecoder(body).Decode(req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		writeError(w, http.StatusBadRequest, decodeError(err))
+		return
+	}
+	if strings.TrimSpace(req.Config) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("missing config"))
+		return
+	}
+
+	var opts []bitbucket.ParseOption
+	if s.resolver != nil {
+		opts = append(opts, bitbucket.WithResolver(s.resolver))
+	}
+	conf, err := bitbucket.ParseString(req.Config, opts...)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	compiled, err := bitbucket.NewCompiler(req.CompilerOptions()...).Compile(conf)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, compiled)
+}
+
+// handleHealth reports that the server is healthy.
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("OK\n"))
+}
+
+// CompilerOptions returns the compiler options of the request, and the
+// transforms registered with bitbucket.RegisterTransform.
+func (req *Request) CompilerOptions() []bitbucket.Option {
+	opts := &bitbucket.RequestOptions{
+		Metadata:      req.Metadata,
+		Prefix:        req.Options.Prefix,
+		WorkspaceBase: req.Options.Workspace.Base,
+		WorkspacePath: req.Options.Workspace.Path,
+		Volumes:       req.Options.Volumes,
+		NetrcUsername: req.Options.Netrc.Username,
+		NetrcPassword: req.Options.Netrc.Password,
+		NetrcMachine:  req.Options.Netrc.Machine,
+		Environ:       req.Options.Environ,
+		Local:         req.Options.Local,
+		Manual:        req.Options.Manual,
+	}
+	return opts.Options()
+}
+
+// decodeError returns the error decoding the request body with the
+// location of the invalid json.
+func decodeError(err error) error {
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		return fmt.Errorf("invalid request at offset %d: %s", syntax.Offset, err)
+	case errors.As(err, &typ) && typ.Field != "":
+		return fmt.Errorf("invalid request field %s at offset %d: %s", typ.Field, typ.Offset, err)
+	case errors.As(err, &typ):
+		return fmt.Errorf("invalid request at offset %d: %s", typ.Offset, err)
+	default:
+		return fmt.Errorf("invalid request: %s", err)
+	}
+}
+
+// writeError writes the error as a diagnostic with the status code.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &Error{
+		Diagnostics: []*bitbucket.Diagnostic{
+			{Message: err.Error()},
+		},
+	})
+}
+
+// writeJSON writes the value as json with the status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
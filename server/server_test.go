@@ -0,0 +1,224 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cncd/bitbucket-frontend"
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+func init() {
+	// the transform only changes the pipelines of the repositories of
+	// TestCompileTransform, so that the other tests are not affected.
+	bitbucket.RegisterTransform("server_test", func(spec *backend.Config, conf *bitbucket.Config, m frontend.Metadata) error {
+		switch m.Repo.Name {
+		case "octocat/labeled":
+			for _, stage := range spec.Stages {
+				for _, step := range stage.Steps {
+					step.Labels = map[string]string{"team": "octocat"}
+				}
+			}
+		case "octocat/rejected":
+			return errors.New("repository not allowed")
+		}
+		return nil
+	})
+}
+
+func TestCompile(t *testing.T) {
+	server := httptest.NewServer(New().Handler())
+	defer server.Close()
+
+	body := `{
+  "config": "image: golang\npipelines:\n  branches:\n    master:\n      - step:\n          script: [ go test ]\n",
+  "metadata": { "curr": { "commit": { "branch": "master", "ref": "refs/heads/master" } } },
+  "options": {
+    "prefix": "test",
+    "workspace": { "base": "/go", "path": "src/github.com/octocat/hello-world" },
+    "volumes": [ "/tmp/cache:/cache" ],
+    "environ": { "GOPATH": "/go" }
+  }
+}`
+	res, err := http.Post(server.URL+"/compile", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer res.Body.Close()
+
+	if want, got := http.StatusOK, res.StatusCode; want != got {
+		t.Errorf("Wanted status %d, got %d", want, got)
+		return
+	}
+	compiled := new(backend.Config)
+	if err := json.NewDecoder(res.Body).Decode(compiled); err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := 2, len(compiled.Stages); want != got {
+		t.Errorf("Wanted %d stages, got %d", want, got)
+		return
+	}
+	step := compiled.Stages[1].Steps[0]
+	if want, got := "test_step_0", step.Name; want != got {
+		t.Errorf("Wanted step name %s, got %s", want, got)
+	}
+	if want, got := "/go/src/github.com/octocat/hello-world", step.WorkingDir; want != got {
+		t.Errorf("Wanted working dir %s, got %s", want, got)
+	}
+	if want, got := "/go", step.Environment["GOPATH"]; want != got {
+		t.Errorf("Wanted GOPATH %s, got %s", want, got)
+	}
+	if want, got := "master", step.Environment["CI_COMMIT_BRANCH"]; want != got {
+		t.Errorf("Wanted branch %s, got %s", want, got)
+	}
+}
+
+func TestCompileTransform(t *testing.T) {
+	handler := New().Handler()
+
+	compile := func(repo string) *httptest.ResponseRecorder {
+		body := `{
+  "config": "pipelines:\n  default:\n    - step:\n        script: [ go test ]\n",
+  "metadata": { "repo": { "name": "` + repo + `" } }
+}`
+		req := httptest.NewRequest(http.MethodPost, "/compile", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := compile("octocat/labeled")
+	if want, got := http.StatusOK, rec.Code; want != got {
+		t.Errorf("Wanted status %d, got %d", want, got)
+		return
+	}
+	compiled := new(backend.Config)
+	if err := json.NewDecoder(rec.Body).Decode(compiled); err != nil {
+		t.Error(err)
+		return
+	}
+	for _, stage := range compiled.Stages {
+		for _, step := range stage.Steps {
+			if want, got := "octocat", step.Labels["team"]; want != got {
+				t.Errorf("Wanted step %s labeled by the registered transform, got labels %v", step.Name, step.Labels)
+			}
+		}
+	}
+
+	rec = compile("octocat/rejected")
+	if want, got := http.StatusUnprocessableEntity, rec.Code; want != got {
+		t.Errorf("Wanted status %d, got %d", want, got)
+		return
+	}
+	out := new(Error)
+	if err := json.NewDecoder(rec.Body).Decode(out); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(out.Diagnostics) != 1 || out.Diagnostics[0].Message != "repository not allowed" {
+		t.Errorf("Expect the transform error as diagnostic, got %v", out.Diagnostics)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	handler := New(WithMaxBytes(256)).Handler()
+
+	tests := []struct {
+		method string
+		body   string
+		status int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "{", http.StatusBadRequest},
+		{http.MethodPost, `{"config": ""}`, http.StatusBadRequest},
+		{http.MethodPost, `{"config": "pipelines: ["}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, `{"config": "` + strings.Repeat("a", 512) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/compile", strings.NewReader(test.body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if want, got := test.status, rec.Code; want != got {
+			t.Errorf("Wanted status %d for %q, got %d", want, test.body, got)
+			continue
+		}
+		out := new(Error)
+		if err := json.NewDecoder(rec.Body).Decode(out); err != nil {
+			t.Error(err)
+			continue
+		}
+		if len(out.Diagnostics) != 1 || out.Diagnostics[0].Message == "" {
+			t.Errorf("Expect a diagnostic for %q", test.body)
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	tests := map[string]string{
+		`{"config": "image: golang",}`: "invalid request at offset 28",
+		`{"config": 42}`:               "invalid request field config at offset 13",
+	}
+	for body, want := range tests {
+		err := json.Unmarshal([]byte(body), new(Request))
+		if got := decodeError(err).Error(); !strings.HasPrefix(got, want) {
+			t.Errorf("Wanted error %q for %s, got %q", want, body, got)
+		}
+	}
+}
+
+func TestCompilerOptionsWorkspace(t *testing.T) {
+	conf, err := bitbucket.ParseString("pipelines:\n  default:\n    - step:\n        script: [ go test ]\n")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	tests := []struct {
+		workspace Workspace
+		want      string
+	}{
+		{Workspace{}, "/workspace/src"},
+		{Workspace{Base: "/go", Path: "src/app"}, "/go/src/app"},
+		{Workspace{Path: "src/app"}, "/workspace/src/app"},
+	}
+	for _, test := range tests {
+		req := &Request{Options: Options{Workspace: test.workspace, Local: true}}
+		compiled, err := bitbucket.NewCompiler(req.CompilerOptions()...).Compile(conf)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if got := compiled.Stages[0].Steps[0].WorkingDir; test.want != got {
+			t.Errorf("Wanted working dir %s for %+v, got %s", test.want, test.workspace, got)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	handler := New(WithTimeout(time.Nanosecond)).Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/compile", bytes.NewBufferString(`{"config": "image: golang"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if want, got := http.StatusServiceUnavailable, rec.Code; want != got {
+		t.Errorf("Wanted status %d, got %d", want, got)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rec := httptest.NewRecorder()
+	New().Handler().ServeHTTP(rec, req)
+	if want, got := http.StatusOK, rec.Code; want != got {
+		t.Errorf("Wanted status %d, got %d", want, got)
+	}
+}
@@ -0,0 +1,126 @@
+package bitbucket
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// markerPrefix is the prefix of the trace markers written to the build
+// logs when trace markers are enabled.
+const markerPrefix = "##ci-trace"
+
+// CommandResult is the result of a command parsed from the build logs.
+type CommandResult struct {
+	// Index is the position of the command in the step script.
+	Index int
+
+	// Command is the command as written to the trace output.
+	Command string
+
+	// Output contains the lines written by the command.
+	Output []string
+
+	// Started and Finished are the times the command started
+	// and finished, with a precision of one second.
+	Started  time.Time
+	Finished time.Time
+
+	// ExitCode is the exit status of the command. It is only
+	// valid if the command exited.
+	ExitCode int
+
+	// Exited is true if the end marker of the command was found.
+	Exited bool
+}
+
+// Duration returns the duration of the command.
+func (r *CommandResult) Duration() time.Duration {
+	if !r.Exited {
+		return 0
+	}
+	return r.Finished.Sub(r.Started)
+}
+
+// ParseTrace parses the build logs of a step compiled with trace markers
+// and returns the result of each command that started. Lines written
+// outside of a command are ignored. Lines are not limited in length.
+func ParseTrace(r io.Reader) ([]*CommandResult, error) {
+	var results []*CommandResult
+	var current *CommandResult
+
+	reader := bufio.NewReader(r)
+	for eof := false; !eof; {
+		line, err := reader.ReadString('\n')
+		switch {
+		case err == io.EOF:
+			eof = true
+			if line == "" {
+				continue
+			}
+		case err != nil:
+			return nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if !strings.HasPrefix(line, markerPrefix+" ") {
+			if current == nil {
+				continue
+			}
+			if current.Command == "" && len(current.Output) == 0 && strings.HasPrefix(line, "+ ") {
+				current.Command = strings.TrimPrefix(line, "+ ")
+				continue
+			}
+			current.Output = append(current.Output, line)
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, markerPrefix))
+		switch {
+		case len(fields) == 3 && fields[0] == "start":
+			index, started, err := parseMarker(fields[1], fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid trace marker %q: %s", line, err)
+			}
+			current = &CommandResult{
+				Index:   index,
+				Started: started,
+			}
+			results = append(results, current)
+		case len(fields) == 4 && fields[0] == "end":
+			index, finished, err := parseMarker(fields[1], fields[3])
+			if err != nil {
+				return nil, fmt.Errorf("invalid trace marker %q: %s", line, err)
+			}
+			code, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid trace marker %q: %s", line, err)
+			}
+			if current == nil || current.Index != index || current.Exited {
+				continue
+			}
+			current.Finished = finished
+			current.ExitCode = code
+			current.Exited = true
+			current = nil
+		default:
+			return nil, fmt.Errorf("invalid trace marker %q", line)
+		}
+	}
+	return results, nil
+}
+
+// parseMarker parses the command index and unix time of a marker.
+func parseMarker(index, unix string) (int, time.Time, error) {
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	sec, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return i, time.Unix(sec, 0), nil
+}
@@ -0,0 +1,103 @@
+package bitbucket
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTrace(t *testing.T) {
+	results, err := ParseTrace(strings.NewReader(traceLog))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := 3, len(results); want != got {
+		t.Errorf("Wanted %d results, got %d", want, got)
+		t.FailNow()
+	}
+
+	build := results[0]
+	if want, got := "go build", build.Command; want != got {
+		t.Errorf("Wanted command %q, got %q", want, got)
+	}
+	if want, got := []string{"building", "done"}, build.Output; strings.Join(want, ",") != strings.Join(got, ",") {
+		t.Errorf("Wanted output %v, got %v", want, got)
+	}
+	if want, got := 12*time.Second, build.Duration(); want != got {
+		t.Errorf("Wanted duration %s, got %s", want, got)
+	}
+
+	test := results[1]
+	if !test.Exited || test.ExitCode != 2 {
+		t.Errorf("Expect go test to exit with code 2")
+	}
+
+	last := results[2]
+	if last.Exited {
+		t.Errorf("Expect unfinished command not exited")
+	}
+	if last.Duration() != 0 {
+		t.Errorf("Expect zero duration for unfinished command")
+	}
+}
+
+func TestParseTraceInvalid(t *testing.T) {
+	_, err := ParseTrace(strings.NewReader("##ci-trace start zero 1486119585\n"))
+	if err == nil {
+		t.Errorf("Expect error parsing an invalid marker")
+	}
+}
+
+func TestParseTraceLongLine(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	log := "##ci-trace start 0 1486119573\n" +
+		"+ cat large.txt\n" +
+		long + "\n" +
+		"##ci-trace end 0 0 1486119585"
+	results, err := ParseTrace(strings.NewReader(log))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := 1, len(results); want != got {
+		t.Errorf("Wanted %d results, got %d", want, got)
+		t.FailNow()
+	}
+	if !results[0].Exited {
+		t.Errorf("Expect command exited after a long output line")
+	}
+	if len(results[0].Output) != 1 || results[0].Output[0] != long {
+		t.Errorf("Expect long output line preserved")
+	}
+}
+
+func TestToScriptMarkers(t *testing.T) {
+	script, _ := base64.StdEncoding.DecodeString(
+		toScript([]string{"go build"}, scriptOptions{markers: true}),
+	)
+	for _, want := range []string{
+		`echo "##ci-trace start 0 $(date +%s)"`,
+		`echo "##ci-trace end 0 0 $(date +%s)"`,
+		"trap ",
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("Expect script to contain %q", want)
+		}
+	}
+}
+
+var traceLog = `Cloning into workspace
+##ci-trace start 0 1486119585
++ go build
+building
+done
+##ci-trace end 0 0 1486119597
+##ci-trace start 1 1486119597
++ go test
+--- FAIL: TestParse
+##ci-trace end 1 2 1486119599
+##ci-trace start 2 1486119599
++ go vet
+`
@@ -0,0 +1,50 @@
+package bitbucket
+
+import (
+	"fmt"
+	"sync"
+)
+
+// registeredTransform is a transform registered by name.
+type registeredTransform struct {
+	name      string
+	transform Transform
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []registeredTransform
+)
+
+// RegisterTransform registers a named transform applied by compilers
+// configured with WithRegisteredTransforms, such as the compilers of the
+// command line tool, the http server and the rpc service, which callers
+// cannot configure with WithTransform. It is meant to be called from the
+// init function of a package linked into the binary, and panics if the
+// name is already registered.
+func RegisterTransform(name string, transform Transform) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, registered := range registry {
+		if registered.name == name {
+			panic(fmt.Sprintf("bitbucket: transform %s registered twice", name))
+		}
+	}
+	registry = append(registry, registeredTransform{name, transform})
+}
+
+// WithRegisteredTransforms configures the compiler with the transforms
+// registered with RegisterTransform, applied in the order they were
+// registered.
+func WithRegisteredTransforms() Option {
+	registryMu.Lock()
+	var transforms []Transform
+	for _, registered := range registry {
+		transforms = append(transforms, registered.transform)
+	}
+	registryMu.Unlock()
+
+	return func(compiler *Compiler) {
+		compiler.transforms = append(compiler.transforms, transforms...)
+	}
+}
@@ -0,0 +1,67 @@
+package bitbucket
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cncd/pipeline/pipeline/backend"
+	"github.com/cncd/pipeline/pipeline/frontend"
+)
+
+func TestWithRegisteredTransforms(t *testing.T) {
+	defer restoreRegistry()()
+
+	var order []string
+	for _, name := range []string{"transform_test_b", "transform_test_a"} {
+		name := name
+		RegisterTransform(name, func(*backend.Config, *Config, frontend.Metadata) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+
+	config, err := ParseString("pipelines:\n  default:\n    - step:\n        script: [ go test ]\n")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	compiler := NewCompiler(
+		WithTransform(func(*backend.Config, *Config, frontend.Metadata) error {
+			order = append(order, "configured")
+			return nil
+		}),
+		WithRegisteredTransforms(),
+	)
+	if _, err := compiler.Compile(config); err != nil {
+		t.Error(err)
+		return
+	}
+	if want, got := "configured transform_test_b transform_test_a", strings.Join(order, " "); want != got {
+		t.Errorf("Wanted transforms applied in order %q, got %q", want, got)
+	}
+}
+
+func TestRegisterTransformTwice(t *testing.T) {
+	defer restoreRegistry()()
+
+	RegisterTransform("transform_test", nil)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expect registering a transform twice to panic")
+		}
+	}()
+	RegisterTransform("transform_test", nil)
+}
+
+// restoreRegistry returns a function restoring the registered transforms
+// to their current state.
+func restoreRegistry() func() {
+	registryMu.Lock()
+	saved := registry
+	registryMu.Unlock()
+	return func() {
+		registryMu.Lock()
+		registry = saved
+		registryMu.Unlock()
+	}
+}
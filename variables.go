@@ -0,0 +1,77 @@
+package bitbucket
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Variables are the sources of the pipeline variables and proxies.
+// Workspace variables are overridden by repository variables, which
+// are overridden by the deployment variables. Overrides take
+// precedence over all other variables.
+type Variables struct {
+	// HTTPProxy, HTTPSProxy and NoProxy configure the proxy
+	// environment variables of every container.
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+
+	// WorkspaceFiles and RepositoryFiles are dotenv files with the
+	// workspace and repository variables.
+	WorkspaceFiles  []string
+	RepositoryFiles []string
+
+	// DeploymentFiles are dotenv files with deployment variables in
+	// the format name=file.
+	DeploymentFiles []string
+
+	// Overrides are variables in the format KEY=VALUE.
+	Overrides []string
+}
+
+// Options returns the compiler options configuring the variables. The
+// dotenv files are read when the options are returned.
+func (v *Variables) Options() ([]Option, error) {
+	var opts []Option
+	if v.HTTPProxy != "" || v.HTTPSProxy != "" || v.NoProxy != "" {
+		opts = append(opts, WithProxy(v.HTTPProxy, v.HTTPSProxy, v.NoProxy))
+	}
+
+	for _, files := range [][]string{v.WorkspaceFiles, v.RepositoryFiles} {
+		if len(files) == 0 {
+			continue
+		}
+		env, err := godotenv.Read(files...)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithEnviron(env))
+	}
+
+	for _, value := range v.DeploymentFiles {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid deployment env file %q, expected name=file", value)
+		}
+		env, err := godotenv.Read(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithDeployment(parts[0], env))
+	}
+
+	overrides := map[string]string{}
+	for _, value := range v.Overrides {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid variable %q, expected KEY=VALUE", value)
+		}
+		overrides[parts[0]] = parts[1]
+	}
+	if len(overrides) != 0 {
+		opts = append(opts, WithOverrides(overrides))
+	}
+	return opts, nil
+}
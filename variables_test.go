@@ -0,0 +1,132 @@
+package bitbucket
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVariables(t *testing.T) {
+	dir, err := ioutil.TempDir("", "variables")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"workspace.env":  "API_URL=workspace\nREGION=us\nPLAN=free\n",
+		"repository.env": "API_URL=repository\nPLAN=team\n",
+		"override.env":   "PLAN=premium\n",
+		"staging.env":    "API_URL=staging\nTOKEN=abc\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	config, err := ParseString(`
+pipelines:
+  default:
+    - step:
+        deployment: staging
+        script: [ make deploy ]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		variables *Variables
+		want      map[string]string
+	}{
+		{
+			name: "repository over workspace",
+			variables: &Variables{
+				WorkspaceFiles:  []string{path("workspace.env")},
+				RepositoryFiles: []string{path("repository.env")},
+			},
+			want: map[string]string{"API_URL": "repository", "REGION": "us", "PLAN": "team"},
+		},
+		{
+			name: "later files over earlier files",
+			variables: &Variables{
+				RepositoryFiles: []string{path("repository.env"), path("override.env")},
+			},
+			want: map[string]string{"API_URL": "repository", "PLAN": "premium"},
+		},
+		{
+			name: "deployment over repository",
+			variables: &Variables{
+				WorkspaceFiles:  []string{path("workspace.env")},
+				RepositoryFiles: []string{path("repository.env")},
+				DeploymentFiles: []string{"staging=" + path("staging.env")},
+			},
+			want: map[string]string{"API_URL": "staging", "REGION": "us", "TOKEN": "abc"},
+		},
+		{
+			name: "overrides over deployment",
+			variables: &Variables{
+				RepositoryFiles: []string{path("repository.env")},
+				DeploymentFiles: []string{"staging=" + path("staging.env")},
+				Overrides:       []string{"API_URL=override", "EMPTY="},
+			},
+			want: map[string]string{"API_URL": "override", "PLAN": "team", "EMPTY": ""},
+		},
+		{
+			name: "overrides over proxy",
+			variables: &Variables{
+				HTTPProxy: "http://proxy:3128",
+				Overrides: []string{"NO_PROXY=localhost"},
+			},
+			want: map[string]string{"HTTP_PROXY": "http://proxy:3128", "NO_PROXY": "localhost"},
+		},
+		{
+			name: "deployment of other environments ignored",
+			variables: &Variables{
+				DeploymentFiles: []string{"production=" + path("staging.env")},
+			},
+			want: map[string]string{"TOKEN": ""},
+		},
+	}
+
+	for _, test := range tests {
+		opts, err := test.variables.Options()
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		compiled, err := NewCompiler(append(opts, WithLocal(true))...).Compile(config)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		env := compiled.Stages[0].Steps[0].Environment
+		for name, want := range test.want {
+			if got := env[name]; got != want {
+				t.Errorf("%s: wanted %s %q, got %q", test.name, name, want, got)
+			}
+		}
+	}
+}
+
+func TestVariablesInvalid(t *testing.T) {
+	tests := []*Variables{
+		{DeploymentFiles: []string{"staging"}},
+		{DeploymentFiles: []string{"=staging.env"}},
+		{DeploymentFiles: []string{"staging=does-not-exist.env"}},
+		{RepositoryFiles: []string{"does-not-exist.env"}},
+		{Overrides: []string{"API_URL"}},
+		{Overrides: []string{"=value"}},
+	}
+	for _, variables := range tests {
+		if _, err := variables.Options(); err == nil {
+			t.Errorf("Expect error for variables %+v", variables)
+		}
+	}
+}